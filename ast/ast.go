@@ -3,7 +3,10 @@ package ast
 
 import (
 	"bytes"
+	"fmt"
 	"monkey/token"
+	"strings"
+	"unicode"
 )
 
 // Node represents a single node in the AST. Every node is expected
@@ -98,6 +101,7 @@ func (ls *LetStatement) String() string {
 	return out.String()
 }
 
+// ReturnStatement represents a return statement. Value is nil for a bare "return;".
 type ReturnStatement struct {
 	Token token.Token // the 'return' token
 	Value Expression
@@ -111,9 +115,9 @@ func (rs *ReturnStatement) TokenLiteral() string {
 // String returns the string representation of the return statement.
 func (rs *ReturnStatement) String() string {
 	var out bytes.Buffer
-	out.WriteString(rs.TokenLiteral() + " ")
+	out.WriteString(rs.TokenLiteral())
 	if rs.Value != nil {
-		out.WriteString(rs.Value.String())
+		out.WriteString(" " + rs.Value.String())
 	}
 	out.WriteString(";")
 	return out.String()
@@ -150,6 +154,73 @@ func (il *IntegerLiteral) String() string {
 	return il.Token.Literal
 }
 
+// FloatLiteral represents a floating-point literal such as 3.14 or 1.5e-3.
+type FloatLiteral struct {
+	Token token.Token
+	Value float64
+}
+
+func (fl *FloatLiteral) expressionNode() {}
+func (fl *FloatLiteral) TokenLiteral() string {
+	return fl.Token.Literal
+}
+
+func (fl *FloatLiteral) String() string {
+	return fl.Token.Literal
+}
+
+// StringLiteral represents a string literal. Value holds the decoded string,
+// with all escape sequences already resolved by the lexer.
+type StringLiteral struct {
+	Token token.Token
+	Value string
+}
+
+func (sl *StringLiteral) expressionNode() {}
+func (sl *StringLiteral) TokenLiteral() string {
+	return sl.Token.Literal
+}
+
+// String returns the string literal in double quotes, re-escaping its value
+// so that the output lexes back to the same string.
+func (sl *StringLiteral) String() string {
+	return quote(sl.Value)
+}
+
+// InterpolatedString represents a string literal with embedded expressions, such as
+// "hello ${name}!". Parts holds, in order, the pieces of text between the expressions and the
+// expressions themselves. Empty pieces of text are left out. The pieces of text are *StringLiteral
+// nodes whose token is the string part they come from, which tells them apart from string
+// literals embedded as expressions, whose token is a STRING.
+type InterpolatedString struct {
+	Token token.Token // the STRING_START token
+	Parts []Expression
+}
+
+func (is *InterpolatedString) expressionNode() {}
+func (is *InterpolatedString) TokenLiteral() string {
+	return is.Token.Literal
+}
+
+// String returns the interpolated string in double quotes, with its expressions in ${...}.
+func (is *InterpolatedString) String() string {
+	var out strings.Builder
+	out.WriteByte('"')
+	for _, part := range is.Parts {
+		if text, ok := part.(*StringLiteral); ok && text.Token.Type != token.STRING {
+			writeEscaped(&out, text.Value)
+			continue
+		}
+		out.WriteString("${")
+		if part != nil {
+			out.WriteString(part.String())
+		}
+		out.WriteByte('}')
+	}
+	out.WriteByte('"')
+	return out.String()
+}
+
 type PrefixExpression struct {
 	Token    token.Token
 	Value    string
@@ -171,6 +242,10 @@ type InfixExpression struct {
 	Left     Expression
 	Operator string
 	Right    Expression
+
+	// ShortCircuit marks the logical operators && and ||, whose Right operand must only be
+	// evaluated when Left does not already decide the result.
+	ShortCircuit bool
 }
 
 func (ie *InfixExpression) expressionNode() {}
@@ -182,6 +257,23 @@ func (ie *InfixExpression) String() string {
 	return "(" + ie.Left.String() + " " + ie.Operator + " " + ie.Right.String() + ")"
 }
 
+// AssignExpression represents an assignment such as x = 5 or arr[0] += 1, which evaluates to the
+// assigned value. A compound assignment x op= y stands for x = x op y, with Target evaluated once.
+type AssignExpression struct {
+	Token    token.Token // the assignment operator token
+	Target   Expression  // an *Identifier or an *IndexExpression
+	Operator string      // "=", or a compound operator such as "+="
+	Value    Expression
+}
+
+func (ae *AssignExpression) expressionNode() {}
+func (ae *AssignExpression) TokenLiteral() string {
+	return ae.Token.Literal
+}
+func (ae *AssignExpression) String() string {
+	return "(" + ae.Target.String() + " " + ae.Operator + " " + ae.Value.String() + ")"
+}
+
 type Boolean struct {
 	Token token.Token
 	Value bool
@@ -212,43 +304,373 @@ func (bs *BlockStatement) String() string {
 	return out.String()
 }
 
+// IfExpression represents a conditional such as if (x) { y } else { z }. In an else-if chain,
+// Alternative is a block holding the nested IfExpression as its only statement, and its token is
+// the nested 'if' token rather than a '{'.
 type IfExpression struct {
 	Token       token.Token     // the 'if' token
 	Condition   Expression      // the condition to evaluate
 	Consequence *BlockStatement // the block to execute if the condition is true
-	Alternative *BlockStatement // the block to execute if the condition is false
+	Alternative *BlockStatement // the block to execute if the condition is false, or nil
 }
 
 func (ie *IfExpression) expressionNode() {}
 func (ie *IfExpression) TokenLiteral() string {
 	return ie.Token.Literal
 }
+
+// String prints the whole chain with its braces, so that the result parses back to the same tree.
 func (ie *IfExpression) String() string {
 	var out bytes.Buffer
-	out.WriteString("if")
+	out.WriteString(ie.TokenLiteral() + " (")
 	out.WriteString(ie.Condition.String())
-	out.WriteString(" ")
-	out.WriteString(ie.Consequence.String())
+	out.WriteString(") ")
+	writeBraced(&out, ie.Consequence)
 	if ie.Alternative != nil {
-		out.WriteString("else ")
-		out.WriteString(ie.Alternative.String())
+		out.WriteString(" else ")
+		if elseIf := ie.ElseIf(); elseIf != nil {
+			out.WriteString(elseIf.String())
+		} else {
+			writeBraced(&out, ie.Alternative)
+		}
+	}
+	return out.String()
+}
+
+// ElseIf returns the next IfExpression of an else-if chain, or nil if the alternative is a plain
+// else block or there is none.
+func (ie *IfExpression) ElseIf() *IfExpression {
+	if ie.Alternative == nil || ie.Alternative.Token.Type != token.IF || len(ie.Alternative.Statements) != 1 {
+		return nil
+	}
+	statement, ok := ie.Alternative.Statements[0].(*ExpressionStatement)
+	if !ok {
+		return nil
+	}
+	elseIf, _ := statement.Expression.(*IfExpression)
+	return elseIf
+}
+
+// writeBraced writes a block between braces, with the statements it holds separated by semicolons.
+func writeBraced(out *bytes.Buffer, bs *BlockStatement) {
+	if len(bs.Statements) == 0 {
+		out.WriteString("{}")
+		return
+	}
+	out.WriteString("{ ")
+	for i, s := range bs.Statements {
+		if i > 0 {
+			out.WriteString(" ")
+		}
+		str := s.String()
+		out.WriteString(str)
+		if i < len(bs.Statements)-1 && !strings.HasSuffix(str, ";") {
+			out.WriteString(";")
+		}
+	}
+	out.WriteString(" }")
+}
+
+// WhileStatement represents a loop such as while (x < 10) { ... }, which runs its body for as
+// long as the condition holds.
+type WhileStatement struct {
+	Token     token.Token // the 'while' token
+	Condition Expression
+	Body      *BlockStatement
+}
+
+func (ws *WhileStatement) statementNode() {}
+func (ws *WhileStatement) TokenLiteral() string {
+	return ws.Token.Literal
+}
+
+func (ws *WhileStatement) String() string {
+	var out bytes.Buffer
+	out.WriteString(ws.TokenLiteral() + " (")
+	out.WriteString(ws.Condition.String())
+	out.WriteString(") ")
+	writeBraced(&out, ws.Body)
+	return out.String()
+}
+
+// ForStatement represents a C-style loop such as for (let i = 0; i < n; i += 1) { ... }. Each of
+// Init, Condition and Update is nil when the corresponding clause is left empty.
+type ForStatement struct {
+	Token     token.Token // the 'for' token
+	Init      Statement   // a let or expression statement, run once before the loop
+	Condition Expression  // checked before every iteration
+	Update    Expression  // evaluated after every iteration
+	Body      *BlockStatement
+}
+
+func (fs *ForStatement) statementNode() {}
+func (fs *ForStatement) TokenLiteral() string {
+	return fs.Token.Literal
+}
+
+func (fs *ForStatement) String() string {
+	var out bytes.Buffer
+	out.WriteString(fs.TokenLiteral() + " (")
+	if fs.Init != nil {
+		out.WriteString(strings.TrimSuffix(fs.Init.String(), ";"))
+	}
+	out.WriteString("; ")
+	if fs.Condition != nil {
+		out.WriteString(fs.Condition.String())
+	}
+	out.WriteString("; ")
+	if fs.Update != nil {
+		out.WriteString(fs.Update.String())
+	}
+	out.WriteString(") ")
+	writeBraced(&out, fs.Body)
+	return out.String()
+}
+
+// ForInStatement represents a loop over the elements of a collection, such as for (x in xs) { ... }.
+type ForInStatement struct {
+	Token    token.Token // the 'for' token
+	Variable *Identifier // bound to each element in turn
+	Iterable Expression
+	Body     *BlockStatement
+}
+
+func (fs *ForInStatement) statementNode() {}
+func (fs *ForInStatement) TokenLiteral() string {
+	return fs.Token.Literal
+}
+
+func (fs *ForInStatement) String() string {
+	var out bytes.Buffer
+	out.WriteString(fs.TokenLiteral() + " (")
+	out.WriteString(fs.Variable.String())
+	out.WriteString(" in ")
+	out.WriteString(fs.Iterable.String())
+	out.WriteString(") ")
+	writeBraced(&out, fs.Body)
+	return out.String()
+}
+
+// BreakStatement represents a break statement, which ends the innermost enclosing loop.
+type BreakStatement struct {
+	Token token.Token // the 'break' token
+}
+
+func (bs *BreakStatement) statementNode() {}
+func (bs *BreakStatement) TokenLiteral() string {
+	return bs.Token.Literal
+}
+func (bs *BreakStatement) String() string {
+	return bs.TokenLiteral() + ";"
+}
+
+// ContinueStatement represents a continue statement, which skips to the next iteration of the
+// innermost enclosing loop.
+type ContinueStatement struct {
+	Token token.Token // the 'continue' token
+}
+
+func (cs *ContinueStatement) statementNode() {}
+func (cs *ContinueStatement) TokenLiteral() string {
+	return cs.Token.Literal
+}
+func (cs *ContinueStatement) String() string {
+	return cs.TokenLiteral() + ";"
+}
+
+// FunctionLiteral represents a function definition such as fn(x, y) { x + y; }.
+type FunctionLiteral struct {
+	Token      token.Token // the 'fn' token
+	Parameters []*Identifier
+	Body       *BlockStatement
+}
+
+func (fl *FunctionLiteral) expressionNode() {}
+func (fl *FunctionLiteral) TokenLiteral() string {
+	return fl.Token.Literal
+}
+
+func (fl *FunctionLiteral) String() string {
+	params := make([]string, len(fl.Parameters))
+	for i, param := range fl.Parameters {
+		params[i] = param.String()
+	}
+
+	var out bytes.Buffer
+	out.WriteString(fl.TokenLiteral())
+	out.WriteString("(")
+	out.WriteString(strings.Join(params, ", "))
+	out.WriteString(") ")
+	writeBraced(&out, fl.Body)
+	return out.String()
+}
+
+// DialectExpression represents a construct added by an embedder's dialect, such as
+// rule "name" { ... } for a dialect keyword rule. Its meaning is up to the embedder, who tells
+// constructs apart by the type of their token.
+type DialectExpression struct {
+	Token     token.Token // the token that starts the construct, usually a dialect keyword
+	Arguments []Expression
+	Body      *BlockStatement // nil if the construct has no block
+}
+
+func (de *DialectExpression) expressionNode() {}
+func (de *DialectExpression) TokenLiteral() string {
+	return de.Token.Literal
+}
+
+func (de *DialectExpression) String() string {
+	var out bytes.Buffer
+	out.WriteString(de.TokenLiteral())
+	for _, arg := range de.Arguments {
+		out.WriteString(" ")
+		out.WriteString(arg.String())
+	}
+	if de.Body != nil {
+		out.WriteString(" ")
+		writeBraced(&out, de.Body)
+	}
+	return out.String()
+}
+
+// CallExpression represents a function call such as add(1, 2). Function is the called
+// expression: an identifier or any expression evaluating to a function, such as a literal.
+type CallExpression struct {
+	Token     token.Token // the '(' token
+	Function  Expression
+	Arguments []Expression
+}
+
+func (ce *CallExpression) expressionNode() {}
+func (ce *CallExpression) TokenLiteral() string {
+	return ce.Token.Literal
+}
+
+func (ce *CallExpression) String() string {
+	args := make([]string, len(ce.Arguments))
+	for i, arg := range ce.Arguments {
+		args[i] = arg.String()
 	}
+
+	var out bytes.Buffer
+	out.WriteString(ce.Function.String())
+	out.WriteString("(")
+	out.WriteString(strings.Join(args, ", "))
+	out.WriteString(")")
+	return out.String()
+}
+
+// ArrayLiteral represents an array literal such as [1, 2 * 3, f(x)].
+type ArrayLiteral struct {
+	Token    token.Token // the '[' token
+	Elements []Expression
+}
+
+func (al *ArrayLiteral) expressionNode() {}
+func (al *ArrayLiteral) TokenLiteral() string {
+	return al.Token.Literal
+}
+
+func (al *ArrayLiteral) String() string {
+	elements := make([]string, len(al.Elements))
+	for i, element := range al.Elements {
+		elements[i] = element.String()
+	}
+
+	var out bytes.Buffer
+	out.WriteString("[")
+	out.WriteString(strings.Join(elements, ", "))
+	out.WriteString("]")
+	return out.String()
+}
+
+// IndexExpression represents an index operation such as arr[i + 1].
+type IndexExpression struct {
+	Token token.Token // the '[' token
+	Left  Expression  // the indexed expression
+	Index Expression
+}
+
+func (ie *IndexExpression) expressionNode() {}
+func (ie *IndexExpression) TokenLiteral() string {
+	return ie.Token.Literal
+}
+
+func (ie *IndexExpression) String() string {
+	var out bytes.Buffer
+	out.WriteString("(")
+	out.WriteString(ie.Left.String())
+	out.WriteString("[")
+	out.WriteString(ie.Index.String())
+	out.WriteString("])")
 	return out.String()
 }
 
-type ElseExpression struct {
-	Token       token.Token     // the 'else' token
-	Consequence *BlockStatement // the block to execute if the condition is false
+// HashLiteral represents a hash literal such as {"a": 1, key: value}. Keys may be arbitrary
+// expressions. Pairs are kept in the order they appear in the source.
+type HashLiteral struct {
+	Token token.Token // the '{' token
+	Pairs []HashPair
+}
+
+// HashPair is a key and its value in a HashLiteral.
+type HashPair struct {
+	Key   Expression
+	Value Expression
 }
 
-func (ee *ElseExpression) expressionNode() {}
-func (ee *ElseExpression) TokenLiteral() string {
-	return ee.Token.Literal
+func (hl *HashLiteral) expressionNode() {}
+func (hl *HashLiteral) TokenLiteral() string {
+	return hl.Token.Literal
 }
 
-func (ee *ElseExpression) String() string {
+func (hl *HashLiteral) String() string {
+	pairs := make([]string, len(hl.Pairs))
+	for i, pair := range hl.Pairs {
+		pairs[i] = pair.Key.String() + ": " + pair.Value.String()
+	}
+
 	var out bytes.Buffer
-	out.WriteString("else ")
-	out.WriteString(ee.Consequence.String())
+	out.WriteString("{")
+	out.WriteString(strings.Join(pairs, ", "))
+	out.WriteString("}")
+	return out.String()
+}
+
+// quote returns s as a double-quoted Monkey string literal, using escape sequences
+// for quotes, backslashes, interpolations and non-printable characters.
+func quote(s string) string {
+	var out strings.Builder
+	out.WriteByte('"')
+	writeEscaped(&out, s)
+	out.WriteByte('"')
 	return out.String()
 }
+
+// writeEscaped writes s to out as the contents of a double-quoted Monkey string literal.
+func writeEscaped(out *strings.Builder, s string) {
+	for i, r := range s {
+		switch r {
+		case '$':
+			if strings.HasPrefix(s[i+1:], "{") {
+				out.WriteString(`\$`)
+			} else {
+				out.WriteByte('$')
+			}
+		case '"':
+			out.WriteString(`\"`)
+		case '\\':
+			out.WriteString(`\\`)
+		case '\n':
+			out.WriteString(`\n`)
+		case '\t':
+			out.WriteString(`\t`)
+		default:
+			if unicode.IsPrint(r) {
+				out.WriteRune(r)
+			} else {
+				fmt.Fprintf(out, "\\u{%x}", r)
+			}
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package lexer
+
+import (
+	"sort"
+	"strings"
+
+	"monkey/token"
+)
+
+// operatorSpelling is an operator or delimiter the lexer recognizes.
+type operatorSpelling struct {
+	literal   string
+	tokenType token.TokenType
+}
+
+// operatorTable indexes operator spellings by their first character, longest spelling first,
+// so that the lexer always picks the longest operator matching the input.
+type operatorTable map[rune][]operatorSpelling
+
+// defaultOperators holds Monkey's own operators and delimiters.
+var defaultOperators = newOperatorTable(token.Operators())
+
+// newOperatorTable builds the table for the given spellings.
+func newOperatorTable(spellings map[string]token.TokenType) operatorTable {
+	table := operatorTable{}
+	for literal, tokenType := range spellings {
+		first := []rune(literal)[0]
+		table[first] = append(table[first], operatorSpelling{literal, tokenType})
+	}
+	for _, candidates := range table {
+		sort.Slice(candidates, func(i, j int) bool {
+			return len(candidates[i].literal) > len(candidates[j].literal)
+		})
+	}
+	return table
+}
+
+// WithDialect makes the lexer recognize the extra keywords and operators of the given dialect.
+func WithDialect(d token.Dialect) Option {
+	return func(l *Lexer) {
+		l.keywords = make(map[string]token.TokenType, len(d.Keywords))
+		for keyword, tokenType := range d.Keywords {
+			l.keywords[keyword] = tokenType
+		}
+
+		spellings := token.Operators()
+		for _, op := range d.Operators {
+			if op.Literal == "" {
+				continue
+			}
+			if first := []rune(op.Literal)[0]; isLetter(first) {
+				l.keywords[op.Literal] = op.Type
+			} else {
+				spellings[op.Literal] = op.Type
+			}
+		}
+		l.operators = newOperatorTable(spellings)
+	}
+}
+
+// lookupIdent returns the token type of an identifier: a dialect keyword, a Monkey keyword or IDENT.
+func (l *Lexer) lookupIdent(ident string) token.TokenType {
+	if tokenType, ok := l.keywords[ident]; ok {
+		return tokenType
+	}
+	return token.LookupIdent(ident)
+}
+
+// readOperator scans the longest operator or delimiter starting at the current character.
+// It reports false, consuming nothing, if no operator matches.
+func (l *Lexer) readOperator() (token.Token, bool) {
+	for _, op := range l.operators[l.currentChar] {
+		if l.hasPrefix(op.literal) {
+			startPos := l.currentPos
+			for l.currentPos < startPos+len(op.literal) {
+				l.readChar()
+			}
+			return token.Token{Type: op.tokenType, Literal: l.slice(startPos, l.currentPos)}, true
+		}
+	}
+	return token.Token{}, false
+}
+
+// hasPrefix reports whether the input continues with s from the current character.
+func (l *Lexer) hasPrefix(s string) bool {
+	for l.reader != nil && l.currentPos-l.base+len(s) > len(l.input) {
+		l.fill()
+	}
+	l.markExamined(l.currentPos + len(s) - 1)
+	return strings.HasPrefix(l.input[l.currentPos-l.base:], s)
+}
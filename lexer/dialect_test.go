@@ -0,0 +1,87 @@
+package lexer
+
+import (
+	"testing"
+
+	"monkey/token"
+)
+
+// Token types of the test dialect.
+var (
+	RULE         = token.Register("RULE")
+	PIPE_FORWARD = token.Register("|>")
+	SPACESHIP    = token.Register("<=>")
+	AT           = token.Register("@")
+	AND_WORD     = token.Register("and")
+)
+
+var testDialect = token.Dialect{
+	Keywords: map[string]token.TokenType{
+		"rule": RULE,
+		"when": token.IF,
+	},
+	Operators: []token.Operator{
+		{Literal: "|>", Type: PIPE_FORWARD},
+		{Literal: "<=>", Type: SPACESHIP},
+		{Literal: "@", Type: AT},
+		{Literal: "and", Type: AND_WORD},
+	},
+}
+
+// TestDialect tests that a dialect adds keywords and operators, matched longest first.
+func TestDialect(t *testing.T) {
+	input := "rule r when (a <=> b) { x |> f and y || z <= @w }"
+	lexer := New(input, WithDialect(testDialect))
+
+	tests := []tokenTest{
+		{RULE, "rule"},
+		{token.IDENT, "r"},
+		{token.IF, "when"},
+		{token.LPAREN, "("},
+		{token.IDENT, "a"},
+		{SPACESHIP, "<=>"},
+		{token.IDENT, "b"},
+		{token.RPAREN, ")"},
+		{token.LBRACE, "{"},
+		{token.IDENT, "x"},
+		{PIPE_FORWARD, "|>"},
+		{token.IDENT, "f"},
+		{AND_WORD, "and"},
+		{token.IDENT, "y"},
+		{token.OR, "||"},
+		{token.IDENT, "z"},
+		{token.LT_EQ, "<="},
+		{AT, "@"},
+		{token.IDENT, "w"},
+		{token.RBRACE, "}"},
+		{token.EOF, ""},
+	}
+
+	runNextTokenTests(tests, lexer, t)
+
+	if len(lexer.Errors()) != 0 {
+		t.Errorf("unexpected errors: %v", lexer.Errors())
+	}
+
+	for tokenType, name := range map[token.TokenType]string{RULE: "RULE", PIPE_FORWARD: "|>", token.IF: "IF"} {
+		if tokenType.String() != name {
+			t.Errorf("token type name wrong. expected=%q, got=%q", name, tokenType.String())
+		}
+	}
+}
+
+// TestDialect_DoesNotLeak tests that a dialect only affects the lexers it is given to.
+func TestDialect_DoesNotLeak(t *testing.T) {
+	New("", WithDialect(testDialect))
+	lexer := New("rule |> @")
+
+	tests := []tokenTest{
+		{token.IDENT, "rule"},
+		{token.PIPE, "|"},
+		{token.GT, ">"},
+		{token.ILLEGAL, "@"},
+		{token.EOF, ""},
+	}
+
+	runNextTokenTests(tests, lexer, t)
+}
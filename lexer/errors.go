@@ -0,0 +1,31 @@
+package lexer
+
+import "monkey/token"
+
+// LexError describes a lexical error: what is wrong and the span of source text concerned.
+type LexError struct {
+	Pos  token.Position // start of the offending text
+	End  token.Position // position just past the offending text
+	Text string         // the offending text
+	Msg  string
+}
+
+// Error formats the error as "position: message".
+func (e LexError) Error() string {
+	return e.Pos.String() + ": " + e.Msg
+}
+
+// Errors returns the lexical errors found so far, in the order they were encountered.
+func (l *Lexer) Errors() []LexError {
+	return l.errors
+}
+
+// addError records a lexical error spanning from pos to the current character.
+func (l *Lexer) addError(pos token.Position, msg string) {
+	l.errors = append(l.errors, LexError{
+		Pos:  pos,
+		End:  l.position(),
+		Text: l.slice(pos.Offset, l.currentPos),
+		Msg:  msg,
+	})
+}
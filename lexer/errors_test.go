@@ -0,0 +1,164 @@
+package lexer
+
+import (
+	"testing"
+
+	"monkey/token"
+)
+
+// TestErrors tests that lexical errors carry a message, a position and the offending span.
+func TestErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []LexError
+	}{
+		{
+			"let x = @#$;",
+			[]LexError{{
+				Pos:  token.Position{Offset: 8, Line: 1, Column: 9},
+				End:  token.Position{Offset: 11, Line: 1, Column: 12},
+				Text: "@#$",
+				Msg:  `illegal characters "@#$"`,
+			}},
+		},
+		{
+			"a € b",
+			[]LexError{{
+				Pos:  token.Position{Offset: 2, Line: 1, Column: 3},
+				End:  token.Position{Offset: 5, Line: 1, Column: 4},
+				Text: "€",
+				Msg:  `illegal character "€"`,
+			}},
+		},
+		{
+			"let a = 1;\x00\x00@ let b = 2;",
+			[]LexError{{
+				Pos:  token.Position{Offset: 10, Line: 1, Column: 11},
+				End:  token.Position{Offset: 13, Line: 1, Column: 14},
+				Text: "\x00\x00@",
+				Msg:  `illegal characters "\x00\x00@"`,
+			}},
+		},
+		{
+			"x\n  123abc",
+			[]LexError{{
+				Pos:  token.Position{Offset: 4, Line: 2, Column: 3},
+				End:  token.Position{Offset: 10, Line: 2, Column: 9},
+				Text: "123abc",
+				Msg:  "invalid character 'a' in decimal literal",
+			}},
+		},
+		{
+			`"a\qb" "open`,
+			[]LexError{
+				{
+					Pos:  token.Position{Offset: 2, Line: 1, Column: 3},
+					End:  token.Position{Offset: 4, Line: 1, Column: 5},
+					Text: `\q`,
+					Msg:  `invalid escape sequence \q`,
+				},
+				{
+					Pos:  token.Position{Offset: 7, Line: 1, Column: 8},
+					End:  token.Position{Offset: 12, Line: 1, Column: 13},
+					Text: `"open`,
+					Msg:  "unterminated string literal",
+				},
+			},
+		},
+		{
+			"/* never\nclosed",
+			[]LexError{{
+				Pos:  token.Position{Offset: 0, Line: 1, Column: 1},
+				End:  token.Position{Offset: 15, Line: 2, Column: 7},
+				Text: "/* never\nclosed",
+				Msg:  "unterminated block comment",
+			}},
+		},
+		{
+			`"a ${b`,
+			[]LexError{{
+				Pos:  token.Position{Offset: 6, Line: 1, Column: 7},
+				End:  token.Position{Offset: 6, Line: 1, Column: 7},
+				Text: "",
+				Msg:  "unterminated string interpolation",
+			}},
+		},
+		{
+			"`raw\nopen",
+			[]LexError{{
+				Pos:  token.Position{Offset: 0, Line: 1, Column: 1},
+				End:  token.Position{Offset: 9, Line: 2, Column: 5},
+				Text: "`raw\nopen",
+				Msg:  "unterminated raw string literal",
+			}},
+		},
+	}
+
+	for _, test := range tests {
+		lexer := New(test.input)
+		for tok := lexer.NextToken(); tok.Type != token.EOF; tok = lexer.NextToken() {
+		}
+
+		errors := lexer.Errors()
+		if len(errors) != len(test.expected) {
+			t.Fatalf("input %q - expected %d errors, got %d: %v", test.input, len(test.expected), len(errors), errors)
+		}
+		for i, expected := range test.expected {
+			if errors[i] != expected {
+				t.Errorf("input %q - errors[%d] wrong.\nexpected=%+v\ngot=     %+v", test.input, i, expected, errors[i])
+			}
+		}
+	}
+}
+
+// TestErrors_Numbers tests the messages reported for malformed number literals.
+func TestErrors_Numbers(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"0x", "hexadecimal literal has no digits"},
+		{"0b_", "binary literal has no digits"},
+		{"0b102", "invalid digit '2' in binary literal"},
+		{"0o78", "invalid digit '8' in octal literal"},
+		{"0xFG", "invalid character 'G' in hexadecimal literal"},
+		{"12abc", "invalid character 'a' in decimal literal"},
+		{"1__000", "'_' must separate successive digits"},
+		{"1_", "'_' must separate successive digits"},
+		{"1_.5", "'_' must separate successive digits"},
+		{"1e", "exponent has no digits"},
+		{"1.5e+", "exponent has no digits"},
+		{"2.5e3x", "invalid character 'x' in decimal literal"},
+	}
+
+	for _, test := range tests {
+		lexer := New(test.input)
+		tok := lexer.NextToken()
+		if tok.Type != token.ILLEGAL || tok.Literal != test.input {
+			t.Errorf("input %q - expected a single ILLEGAL token, got %q %q", test.input, tok.Type, tok.Literal)
+		}
+		if errors := lexer.Errors(); len(errors) != 1 || errors[0].Msg != test.expected {
+			t.Errorf("input %q - expected error %q, got %v", test.input, test.expected, errors)
+		}
+	}
+
+	for _, valid := range []string{"0", "007", "0123", "09", "0_9", "0x_FF", "0B1_0", "1_000", "1.5e-3", "1_0.0_1E+1_0"} {
+		lexer := New(valid)
+		lexer.NextToken()
+		if errors := lexer.Errors(); len(errors) != 0 {
+			t.Errorf("input %q - expected no errors, got %v", valid, errors)
+		}
+	}
+}
+
+// TestErrors_LosslessTrailingComment tests that an unterminated comment probed as trailing trivia is
+// reported exactly once.
+func TestErrors_LosslessTrailingComment(t *testing.T) {
+	lexer := New("x /* open", WithTrivia())
+	for tok := lexer.NextToken(); tok.Type != token.EOF; tok = lexer.NextToken() {
+	}
+
+	if errors := lexer.Errors(); len(errors) != 1 || errors[0].Msg != "unterminated block comment" {
+		t.Fatalf("expected a single unterminated comment error, got %v", lexer.Errors())
+	}
+}
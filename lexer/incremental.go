@@ -0,0 +1,218 @@
+package lexer
+
+import (
+	"fmt"
+	"sort"
+
+	"monkey/token"
+)
+
+// state is the lexer state at a token boundary, from which lexing can be restarted.
+type state struct {
+	offset         int
+	line           int
+	column         int
+	interpolations []int // see Lexer.interpolations
+}
+
+// state returns the current state of the lexer. It is only meaningful between two tokens.
+func (l *Lexer) state() state {
+	interpolations := append([]int(nil), l.interpolations...)
+	return state{offset: l.currentPos, line: l.line, column: l.column, interpolations: interpolations}
+}
+
+// sameMode reports whether two states lex what follows them the same way.
+func (s state) sameMode(other state) bool {
+	if len(s.interpolations) != len(other.interpolations) {
+		return false
+	}
+	for i := range s.interpolations {
+		if s.interpolations[i] != other.interpolations[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// restart moves the lexer to a state previously returned by state, possibly on another lexer
+// over an edited version of the same input, and forgets the errors reported so far.
+func (l *Lexer) restart(s state) {
+	l.currentPos = s.offset
+	l.line, l.column = s.line, s.column
+	l.interpolations = append([]int(nil), s.interpolations...)
+	l.currentChar, l.nextPos = l.decodeAt(s.offset)
+	l.errors = nil
+}
+
+// Edit replaces the source text between the byte offsets Start and End with Text.
+type Edit struct {
+	Start int
+	End   int
+	Text  string
+}
+
+// Document is a lexed source text that can be edited in place, as in an editor. Applying an edit
+// only re-scans the tokens around it: the tokens before it are kept as they are, and the tokens
+// after it are shifted as soon as the lexer is back in sync with the previous token list.
+type Document struct {
+	src     string
+	opts    []Option
+	records []tokenRecord
+
+	rescanned int // number of tokens scanned by the last call to Apply
+}
+
+// tokenRecord holds a token of a Document along with what is needed to re-lex around it.
+type tokenRecord struct {
+	tok      token.Token
+	start    state      // lexer state when scanning of the token began, before its leading whitespace
+	examined int        // offset of the last byte looked at while scanning this token or any before it
+	errors   []LexError // the lexical errors reported while scanning the token
+}
+
+// NewDocument lexes src with the given options and returns it as an editable Document.
+func NewDocument(src string, opts ...Option) *Document {
+	d := &Document{src: src, opts: opts}
+	l := New(src, opts...)
+	examined := -1
+	for {
+		record := d.scan(l, examined)
+		examined = record.examined
+		d.records = append(d.records, record)
+		if record.tok.Type == token.EOF {
+			break
+		}
+	}
+	d.rescanned = len(d.records)
+	return d
+}
+
+// Source returns the current source text of the document.
+func (d *Document) Source() string {
+	return d.src
+}
+
+// Tokens returns the tokens of the document, the last one being EOF.
+func (d *Document) Tokens() []token.Token {
+	tokens := make([]token.Token, len(d.records))
+	for i, record := range d.records {
+		tokens[i] = record.tok
+	}
+	return tokens
+}
+
+// Errors returns the lexical errors of the document, in source order.
+func (d *Document) Errors() []LexError {
+	var errors []LexError
+	for _, record := range d.records {
+		errors = append(errors, record.errors...)
+	}
+	return errors
+}
+
+// Apply applies the edit to the document and returns its updated tokens.
+func (d *Document) Apply(e Edit) ([]token.Token, error) {
+	if e.Start < 0 || e.Start > e.End || e.End > len(d.src) {
+		return nil, fmt.Errorf("edit range [%d, %d) out of bounds [0, %d)", e.Start, e.End, len(d.src))
+	}
+	src := d.src[:e.Start] + e.Text + d.src[e.End:]
+	delta := len(e.Text) - (e.End - e.Start)
+
+	// Tokens scanned without looking at the edited bytes are unaffected. The EOF token is recorded
+	// as having looked at the end of the input (see scan), so there is at least one token to re-scan.
+	first := sort.Search(len(d.records), func(i int) bool {
+		return d.records[i].examined >= e.Start
+	})
+
+	l := New(src, d.opts...)
+	l.restart(d.records[first].start)
+
+	// Re-scan until the lexer reaches, past the edit, the point where one of the old tokens started,
+	// in the same mode. From there on it would produce the old tokens again, only shifted.
+	var fresh []tokenRecord
+	resume := first
+	for {
+		current := l.state()
+		for resume < len(d.records) && (d.records[resume].start.offset < e.End || d.records[resume].start.offset+delta < current.offset) {
+			resume++
+		}
+		if resume < len(d.records) && d.records[resume].start.offset+delta == current.offset && d.records[resume].start.sameMode(current) {
+			break
+		}
+
+		examined := -1
+		if n := len(fresh); n > 0 {
+			examined = fresh[n-1].examined
+		} else if first > 0 {
+			examined = d.records[first-1].examined
+		}
+		record := d.scan(l, examined)
+		fresh = append(fresh, record)
+		if record.tok.Type == token.EOF {
+			resume = len(d.records)
+			break
+		}
+	}
+
+	records := make([]tokenRecord, 0, first+len(fresh)+len(d.records)-resume)
+	records = append(records, d.records[:first]...)
+	records = append(records, fresh...)
+	if resume < len(d.records) {
+		from, to := d.records[resume].start, l.state()
+		for _, record := range d.records[resume:] {
+			records = append(records, record.shift(from, to))
+		}
+	}
+
+	d.src = src
+	d.records = records
+	d.rescanned = len(fresh)
+	return d.Tokens(), nil
+}
+
+// scan lexes the next token with l and records it. examined is the value recorded for the tokens
+// scanned before, which the high-water mark of the new token cannot be lower than.
+func (d *Document) scan(l *Lexer, examined int) tokenRecord {
+	start := l.state()
+	l.examined = examined
+	errorCount := len(l.errors)
+
+	tok := l.NextToken()
+	if tok.Type == token.EOF {
+		// Text appended to the input would follow the last token, or make up the whole input.
+		l.markExamined(l.currentPos)
+	}
+
+	record := tokenRecord{tok: tok, start: start, examined: l.examined}
+	if len(l.errors) > errorCount {
+		record.errors = append([]LexError(nil), l.errors[errorCount:]...)
+	}
+	return record
+}
+
+// shift moves a token record that followed an edit to its new place. from is the state at which the
+// first reused token started before the edit, and to is the corresponding state after it.
+func (r tokenRecord) shift(from, to state) tokenRecord {
+	shiftPos := func(pos token.Position) token.Position {
+		if pos.Line == from.line {
+			pos.Column += to.column - from.column
+		}
+		pos.Line += to.line - from.line
+		pos.Offset += to.offset - from.offset
+		return pos
+	}
+
+	r.tok.Pos = shiftPos(r.tok.Pos)
+	start := shiftPos(token.Position{Offset: r.start.offset, Line: r.start.line, Column: r.start.column})
+	r.start = state{offset: start.Offset, line: start.Line, column: start.Column, interpolations: r.start.interpolations}
+	r.examined += to.offset - from.offset
+	if r.errors != nil {
+		errors := make([]LexError, len(r.errors))
+		for i, err := range r.errors {
+			err.Pos, err.End = shiftPos(err.Pos), shiftPos(err.End)
+			errors[i] = err
+		}
+		r.errors = errors
+	}
+	return r
+}
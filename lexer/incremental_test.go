@@ -0,0 +1,165 @@
+package lexer
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestDocument_Apply tests that a document edited incrementally always holds the tokens and errors
+// of a full lexing of its new source.
+func TestDocument_Apply(t *testing.T) {
+	edits := []struct {
+		old  string // text to replace, found by its first occurrence in the source
+		text string
+	}{
+		{"café", "cafés"},
+		{"let add", "let  add"},
+		{"héllo", "hi"},
+		{"\"hi", "hi"},            // opens the string elsewhere
+		{"/* nested", "/ nested"}, // breaks the block comment
+		{"1_000", "1_000.5e"},
+		{"x + y", "x +\n\n y"},
+		{"3.14e-2", "3"},
+		{"\n", ""},
+		{"// greeting", ""},
+		{"€", "€€ "},
+		{"\"unterminated", "\"terminated\""},
+		{"${y}", "${y + 1}"},
+		{"\"a ${", "\"a "}, // leaves the braces of the interpolation unbalanced
+		{"`raw", "raw"},
+		{"c\"", "${ c\""},
+		{"", "let z = 1;\n"},
+	}
+
+	for _, opts := range [][]Option{{}, {WithComments()}, {WithTrivia()}} {
+		d := NewDocument(readerTestInput, opts...)
+		src := readerTestInput
+		for _, edit := range edits {
+			start := strings.Index(src, edit.old)
+			if start < 0 {
+				t.Fatalf("%q not found in source %q", edit.old, src)
+			}
+			e := Edit{Start: start, End: start + len(edit.old), Text: edit.text}
+			src = src[:e.Start] + e.Text + src[e.End:]
+
+			got, err := d.Apply(e)
+			if err != nil {
+				t.Fatalf("Apply(%+v) returned error: %v", e, err)
+			}
+			if d.Source() != src {
+				t.Fatalf("Apply(%+v) - source wrong. expected=%q, got=%q", e, src, d.Source())
+			}
+
+			l := New(src, opts...)
+			expected := collectTokens(l)
+			if !reflect.DeepEqual(got, expected) {
+				t.Fatalf("Apply(%+v) - tokens wrong.\nexpected=%+v\ngot=%+v", e, expected, got)
+			}
+			if !reflect.DeepEqual(d.Errors(), l.Errors()) {
+				t.Fatalf("Apply(%+v) - errors wrong.\nexpected=%+v\ngot=%+v", e, l.Errors(), d.Errors())
+			}
+		}
+	}
+}
+
+// TestDocument_ApplyRescansLocally tests that editing a large document only re-scans the tokens
+// around the edit.
+func TestDocument_ApplyRescansLocally(t *testing.T) {
+	src := strings.Repeat("let x = 12345 + y; // some filler\n", 1000)
+	d := NewDocument(src)
+
+	tests := []struct {
+		edit      Edit
+		maxTokens int
+	}{
+		{Edit{Start: 4, End: 5, Text: "abc"}, 2},
+		{Edit{Start: 500, End: 500, Text: "\n\n"}, 5}, // splits a comment
+		{Edit{Start: 1000, End: 1003, Text: ""}, 3},
+		{Edit{Start: 2000, End: 2000, Text: "fn"}, 3},
+	}
+
+	for _, tt := range tests {
+		if _, err := d.Apply(tt.edit); err != nil {
+			t.Fatalf("Apply(%+v) returned error: %v", tt.edit, err)
+		}
+		if d.rescanned > tt.maxTokens {
+			t.Errorf("Apply(%+v) re-scanned %d tokens, expected at most %d", tt.edit, d.rescanned, tt.maxTokens)
+		}
+	}
+
+	if expected := collectTokens(New(d.Source())); !reflect.DeepEqual(d.Tokens(), expected) {
+		t.Fatalf("tokens differ from a full lexing of the edited source")
+	}
+}
+
+// TestDocument_ApplyAtEnd tests edits at the very end of a document, including an empty one.
+func TestDocument_ApplyAtEnd(t *testing.T) {
+	tests := []struct {
+		src  string
+		edit Edit
+	}{
+		{"x", Edit{Start: 1, End: 1, Text: "y"}},
+		{"let x = 1;", Edit{Start: 10, End: 10, Text: " y"}},
+		{"let x = 1", Edit{Start: 9, End: 9, Text: "0;"}},
+		{"", Edit{Start: 0, End: 0, Text: "x"}},
+		{"x // comment", Edit{Start: 12, End: 12, Text: "\ny"}},
+		{"x", Edit{Start: 0, End: 1, Text: ""}},
+	}
+
+	for _, tt := range tests {
+		for _, opts := range [][]Option{{}, {WithTrivia()}} {
+			d := NewDocument(tt.src, opts...)
+			got, err := d.Apply(tt.edit)
+			if err != nil {
+				t.Fatalf("%q: Apply(%+v) returned error: %v", tt.src, tt.edit, err)
+			}
+			expected := collectTokens(New(d.Source(), opts...))
+			if !reflect.DeepEqual(got, expected) {
+				t.Errorf("%q: Apply(%+v) - tokens wrong.\nexpected=%+v\ngot=%+v", tt.src, tt.edit, expected, got)
+			}
+		}
+	}
+}
+
+// TestDocument_ApplyCompletesUTF8 tests that an edit completing an invalid UTF-8 sequence re-scans
+// the token that ended on its first byte.
+func TestDocument_ApplyCompletesUTF8(t *testing.T) {
+	tests := []struct {
+		src  string
+		edit Edit
+	}{
+		{"x\xc3 \xa9", Edit{Start: 2, End: 3, Text: ""}},
+		{"x\xe2\x82 y", Edit{Start: 3, End: 3, Text: "\xac"}},
+		{"\"a\xf0 \x9f\x98\x80\"", Edit{Start: 3, End: 4, Text: ""}},
+	}
+
+	for _, tt := range tests {
+		d := NewDocument(tt.src)
+		got, err := d.Apply(tt.edit)
+		if err != nil {
+			t.Fatalf("%q: Apply(%+v) returned error: %v", tt.src, tt.edit, err)
+		}
+		l := New(d.Source())
+		if expected := collectTokens(l); !reflect.DeepEqual(got, expected) {
+			t.Errorf("%q: Apply(%+v) - tokens wrong.\nexpected=%+v\ngot=%+v", tt.src, tt.edit, expected, got)
+		}
+		if !reflect.DeepEqual(d.Errors(), l.Errors()) {
+			t.Errorf("%q: Apply(%+v) - errors wrong.\nexpected=%+v\ngot=%+v", tt.src, tt.edit, l.Errors(), d.Errors())
+		}
+	}
+}
+
+// TestDocument_ApplyInvalidRange tests that edits outside of the document are rejected.
+func TestDocument_ApplyInvalidRange(t *testing.T) {
+	d := NewDocument("let x = 1;")
+
+	for _, e := range []Edit{{Start: -1, End: 0}, {Start: 3, End: 2}, {Start: 0, End: 11}} {
+		if _, err := d.Apply(e); err == nil {
+			t.Errorf("Apply(%+v) - expected an error", e)
+		}
+	}
+	if d.Source() != "let x = 1;" {
+		t.Errorf("source changed by invalid edits: %q", d.Source())
+	}
+}
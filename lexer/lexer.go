@@ -1,151 +1,645 @@
 // Package lexer implements lexical tokenization for the Monkey programming language.
 package lexer
 
-import "monkey/token"
+import (
+	"fmt"
+	"io"
+	"monkey/token"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
 
 // Lexer represents a lexical scanner for tokenizing the Monkey programming language.
 type Lexer struct {
-	input       string
-	currentChar byte
+	input       string // the source text, or the buffered window of it when reading from an io.Reader
+	base        int    // offset of input[0] within the source
+	filename    string
+	currentChar rune
 	currentPos  int
 	nextPos     int
+	line        int // line of currentChar, starting at 1
+	column      int // column of currentChar, starting at 1
+
+	emitComments bool
+	keepTrivia   bool
+	keywords     map[string]token.TokenType // dialect keywords, checked before Monkey's own
+	operators    operatorTable
+
+	// interpolations holds one entry per string interpolation (${...}) being lexed, innermost last:
+	// the number of braces opened within it and not closed yet. The "}" that closes the
+	// interpolation is the one met when that number is zero; the string resumes after it.
+	interpolations []int
+
+	errors   []LexError
+	examined int // offset of the last byte looked at, used to tell which tokens an edit affects
+
+	// Streaming state, only used by lexers created with NewReader.
+	reader     io.Reader
+	chunk      []byte // read buffer, reused across reads
+	tokenStart int    // offset of the first byte the current token may still refer to
+	readErr    error
+}
+
+// Option configures optional behaviour of a Lexer.
+type Option func(*Lexer)
+
+// WithFilename sets the file name recorded in the position of every token.
+func WithFilename(filename string) Option {
+	return func(l *Lexer) {
+		l.filename = filename
+	}
+}
+
+// WithComments makes the lexer emit comments as COMMENT tokens instead of skipping them,
+// so that tools such as formatters can see them.
+func WithComments() Option {
+	return func(l *Lexer) {
+		l.emitComments = true
+	}
+}
+
+// WithTrivia makes the lexer lossless: whitespace and comments are attached to the tokens as
+// leading and trailing trivia instead of being thrown away, so that refactoring tools and
+// formatters can reproduce the exact layout of the source. Comments still come out as COMMENT
+// tokens when combined with WithComments.
+func WithTrivia() Option {
+	return func(l *Lexer) {
+		l.keepTrivia = true
+	}
 }
 
 // New returns a new instance of the Lexer, initialized with the provided input string.
-func New(input string) *Lexer {
-	l := &Lexer{input: input}
+func New(input string, opts ...Option) *Lexer {
+	return newLexer(&Lexer{input: input}, opts)
+}
 
-	if len(input) > 0 {
-		l.currentChar = input[0]
-		l.nextPos = 1
-		// no need to set l.currentPos to 0, Go already instanciates it with its struct type's zero value
+// newLexer applies the options to l and positions it on the first character of its input.
+func newLexer(l *Lexer, opts []Option) *Lexer {
+	l.line = 1
+	l.column = 1
+	l.operators = defaultOperators
+	for _, opt := range opts {
+		opt(l)
 	}
 
+	// no need to set l.currentPos to 0, Go already instanciates it with its struct type's zero value
+	l.currentChar, l.nextPos = l.decodeAt(0)
+
 	return l
 }
 
 // NextToken scans and returns the next token from the input.
 func (l *Lexer) NextToken() token.Token {
-	var tok token.Token
+	leadingPos := l.currentPos
+	l.tokenStart = leadingPos
 
-	l.skipWhitespace()
+	var tok token.Token
+	for {
+		l.skipWhitespace()
+		if !l.keepTrivia {
+			l.tokenStart = l.currentPos
+		}
 
-	switch l.currentChar {
-	case '=':
-		tok = l.handleTwoCharToken(token.ASSIGN, '=', token.EQ)
-	case '+':
-		tok = l.handleSingleCharToken(token.PLUS)
-	case '(':
-		tok = l.handleSingleCharToken(token.LPAREN)
-	case ')':
-		tok = l.handleSingleCharToken(token.RPAREN)
-	case '{':
-		tok = l.handleSingleCharToken(token.LBRACE)
-	case '}':
-		tok = l.handleSingleCharToken(token.RBRACE)
-	case ',':
-		tok = l.handleSingleCharToken(token.COMMA)
-	case ';':
-		tok = l.handleSingleCharToken(token.SEMICOLON)
-	case '-':
-		tok = l.handleSingleCharToken(token.MINUS)
-	case '/':
-		tok = l.handleSingleCharToken(token.SLASH)
-	case '*':
-		tok = l.handleSingleCharToken(token.ASTERISK)
-	case '<':
-		tok = l.handleSingleCharToken(token.LT)
-	case '>':
-		tok = l.handleSingleCharToken(token.GT)
-	case '!':
-		tok = l.handleTwoCharToken(token.BANG, '=', token.NOT_EQ)
-	case 0:
-		tok = token.Token{Type: token.EOF, Literal: ""}
-	default:
-		if isLetter(l.currentChar) {
-			tok.Literal = l.readIdentifier()
-			tok.Type = token.LookupIdent(tok.Literal)
-			return tok
-		} else if isDigit(l.currentChar) {
-			tok.Type = token.INT
-			tok.Literal = l.readNumber()
-			return tok
+		pos := l.position()
+		if l.atComment() {
+			tok = l.readComment()
+			if tok.Type == token.COMMENT && !l.emitComments {
+				continue
+			}
 		} else {
-			tok = token.Token{Type: token.ILLEGAL, Literal: string(l.currentChar)}
+			tok = l.readToken()
 		}
+		tok.Pos = pos
+		tok.Raw = l.slice(pos.Offset, l.currentPos)
+		break
 	}
 
-	l.readChar()
+	if l.keepTrivia {
+		tok.Leading = l.slice(leadingPos, tok.Pos.Offset)
+		tok.Trailing = l.readTrailingTrivia()
+	}
 	return tok
 }
 
+// readTrailingTrivia consumes the whitespace and comments that follow a token on its line, stopping
+// before the line break. An unterminated block comment is left in place so that it is reported as
+// an ILLEGAL token.
+func (l *Lexer) readTrailingTrivia() string {
+	startPos := l.currentPos
+	for {
+		switch {
+		case l.currentChar == ' ' || l.currentChar == '\t' || l.currentChar == '\r':
+			l.readChar()
+		case l.atComment() && !l.emitComments:
+			mark := l.mark()
+			if comment := l.readComment(); comment.Type != token.COMMENT {
+				l.reset(mark)
+				return l.slice(startPos, l.currentPos)
+			}
+		default:
+			return l.slice(startPos, l.currentPos)
+		}
+	}
+}
+
+// readToken scans the token starting at the current character.
+func (l *Lexer) readToken() token.Token {
+	switch {
+	case l.atEnd():
+		if len(l.interpolations) > 0 {
+			l.interpolations = nil
+			l.addError(l.position(), "unterminated string interpolation")
+		}
+		return token.Token{Type: token.EOF, Literal: ""}
+	case l.currentChar == '"':
+		return l.readString(token.STRING, token.STRING_START)
+	case l.currentChar == '`':
+		return l.readRawString()
+	case l.currentChar == '}' && len(l.interpolations) > 0 && l.interpolations[len(l.interpolations)-1] == 0:
+		l.interpolations = l.interpolations[:len(l.interpolations)-1]
+		return l.readString(token.STRING_END, token.STRING_MIDDLE)
+	case isLetter(l.currentChar):
+		literal := l.readIdentifier()
+		return token.Token{Type: l.lookupIdent(literal), Literal: literal}
+	case isDigit(l.currentChar):
+		return l.readNumber()
+	}
+
+	if tok, ok := l.readOperator(); ok {
+		if n := len(l.interpolations); n > 0 {
+			switch tok.Type {
+			case token.LBRACE:
+				l.interpolations[n-1]++
+			case token.RBRACE:
+				l.interpolations[n-1]--
+			}
+		}
+		return tok
+	}
+	return l.readIllegal()
+}
+
+// atIllegal reports whether the current character cannot start any token. A NUL character is
+// illegal: only the end of the input ends the input.
+func (l *Lexer) atIllegal() bool {
+	ch := l.currentChar
+	return !l.atEnd() && ch != '"' && ch != '`' && !isLetter(ch) && !isDigit(ch) && !isWhitespace(ch) && len(l.operators[ch]) == 0
+}
+
+// readIllegal scans a run of characters that cannot start a token, such as "@#$", and reports
+// it as a single ILLEGAL token and a single error.
+func (l *Lexer) readIllegal() token.Token {
+	pos := l.position()
+	for l.readChar(); l.atIllegal(); {
+		l.readChar()
+	}
+
+	literal := l.slice(pos.Offset, l.currentPos)
+	if utf8.RuneCountInString(literal) == 1 {
+		l.addError(pos, fmt.Sprintf("illegal character %q", literal))
+	} else {
+		l.addError(pos, fmt.Sprintf("illegal characters %q", literal))
+	}
+	return token.Token{Type: token.ILLEGAL, Literal: literal}
+}
+
 // skipWhitespace advances the scanner until a non-whitespace character is encountered.
 func (l *Lexer) skipWhitespace() {
-	for l.currentChar == ' ' || l.currentChar == '\t' || l.currentChar == '\n' || l.currentChar == '\r' {
+	for isWhitespace(l.currentChar) {
 		l.readChar()
 	}
 }
 
-// handleTwoCharToken checks if the next character matches the expected character for a two-character token.
-func (l *Lexer) handleTwoCharToken(defaultType token.TokenType, expectedChar byte, twoCharType token.TokenType) token.Token {
-	if l.peekChar() == expectedChar {
-		ch := l.currentChar
-		l.readChar()
-		return token.Token{Type: twoCharType, Literal: string(ch) + string(l.currentChar)}
+// atComment reports whether a line comment (//) or block comment (/*) starts at the current character.
+func (l *Lexer) atComment() bool {
+	return l.currentChar == '/' && (l.peekChar() == '/' || l.peekChar() == '*')
+}
+
+// readComment scans a line comment, which runs up to the end of the line, or a block comment,
+// which may contain nested block comments. An unterminated block comment yields an ILLEGAL token.
+func (l *Lexer) readComment() token.Token {
+	pos := l.position()
+	startPos := pos.Offset
+
+	if l.peekChar() == '/' {
+		for l.currentChar != '\n' && !l.atEnd() {
+			l.readChar()
+		}
+		return token.Token{Type: token.COMMENT, Literal: l.slice(startPos, l.currentPos)}
 	}
-	return l.handleSingleCharToken(defaultType)
+
+	l.readChar()
+	l.readChar() // skip "/*"
+	for depth := 1; depth > 0; l.readChar() {
+		switch {
+		case l.atEnd():
+			l.addError(pos, "unterminated block comment")
+			return token.Token{Type: token.ILLEGAL, Literal: l.slice(startPos, l.currentPos)}
+		case l.currentChar == '/' && l.peekChar() == '*':
+			depth++
+			l.readChar()
+		case l.currentChar == '*' && l.peekChar() == '/':
+			depth--
+			l.readChar()
+		}
+	}
+	return token.Token{Type: token.COMMENT, Literal: l.slice(startPos, l.currentPos)}
+}
+
+// cursor records the scanning position of a Lexer and the number of errors reported so far.
+type cursor struct {
+	currentChar rune
+	currentPos  int
+	nextPos     int
+	line        int
+	column      int
+	errors      int
+}
+
+// mark returns the current scanning position, to be restored with reset.
+func (l *Lexer) mark() cursor {
+	return cursor{l.currentChar, l.currentPos, l.nextPos, l.line, l.column, len(l.errors)}
 }
 
-// handleSingleCharToken returns a token of the given type with the current character as its literal.
-func (l *Lexer) handleSingleCharToken(t token.TokenType) token.Token {
-	return token.Token{Type: t, Literal: string(l.currentChar)}
+// reset moves the lexer back to a position previously returned by mark, forgetting the errors
+// reported since. The position must not precede the start of the current token, which is the
+// oldest input a streaming lexer keeps.
+func (l *Lexer) reset(c cursor) {
+	l.currentChar, l.currentPos, l.nextPos, l.line, l.column = c.currentChar, c.currentPos, c.nextPos, c.line, c.column
+	l.errors = l.errors[:c.errors]
 }
 
-// readChar reads the next character from the input and updates the current and next positions.
+// position returns the source position of the current character.
+func (l *Lexer) position() token.Position {
+	return token.Position{
+		Filename: l.filename,
+		Offset:   l.currentPos,
+		Line:     l.line,
+		Column:   l.column,
+	}
+}
+
+// readChar reads the next character from the input and updates the current and next positions,
+// as well as the line and column of the new current character. Once the end of the input has
+// been reached, further calls have no effect.
 func (l *Lexer) readChar() {
-	if l.nextPos >= len(l.input) {
-		l.currentChar = 0
+	if l.atEnd() {
+		return
+	}
+	if l.currentChar == '\n' {
+		l.line++
+		l.column = 1
 	} else {
-		l.currentChar = l.input[l.nextPos]
+		l.column++
 	}
 	l.currentPos = l.nextPos
-	l.nextPos++
+	l.currentChar, l.nextPos = l.decodeAt(l.nextPos)
+}
+
+// atEnd reports whether the whole input has been read. The current character is then 0, which
+// a NUL character in the input also decodes to.
+func (l *Lexer) atEnd() bool {
+	return l.currentPos-l.base >= len(l.input)
 }
 
 // peekChar returns the next character from the input without advancing the current and next positions.
-func (l *Lexer) peekChar() byte {
-	if l.nextPos >= len(l.input) {
-		return 0
+func (l *Lexer) peekChar() rune {
+	ch, _ := l.decodeAt(l.nextPos)
+	return ch
+}
+
+// decodeAt decodes the UTF-8 character starting at the given byte offset and returns it together
+// with the offset of the character that follows. Past the end of the input it returns 0.
+// An invalid byte decodes to utf8.RuneError with a width of one byte.
+func (l *Lexer) decodeAt(pos int) (rune, int) {
+	for l.reader != nil && pos-l.base+utf8.UTFMax > len(l.input) {
+		l.fill()
+	}
+	if pos-l.base >= len(l.input) {
+		l.markExamined(pos)
+		return 0, pos + 1
 	}
-	return l.input[l.nextPos]
+	ch, width := utf8.DecodeRuneInString(l.input[pos-l.base:])
+	if ch == utf8.RuneError && width == 1 {
+		// Telling an invalid byte from a valid sequence may take up to UTFMax bytes.
+		end := pos + utf8.UTFMax
+		if inputEnd := l.base + len(l.input); end > inputEnd {
+			end = inputEnd
+		}
+		l.markExamined(end - 1)
+	} else {
+		l.markExamined(pos + width - 1)
+	}
+	return ch, pos + width
 }
 
-// readIdentifier scans an identifier from the input, capturing characters until a non-letter is encountered.
+// markExamined records that the lexer has looked at the input up to the given offset.
+func (l *Lexer) markExamined(pos int) {
+	if pos > l.examined {
+		l.examined = pos
+	}
+}
+
+// slice returns the source text between the given offsets.
+func (l *Lexer) slice(start, end int) string {
+	return l.input[start-l.base : end-l.base]
+}
+
+// readIdentifier scans an identifier from the input: a letter followed by any number of letters and digits.
 func (l *Lexer) readIdentifier() string {
 	startPos := l.currentPos
-	for isLetter(l.currentChar) {
+	for isLetter(l.currentChar) || unicode.IsDigit(l.currentChar) {
+		l.readChar()
+	}
+	return l.slice(startPos, l.currentPos)
+}
+
+// readNumber scans an integer or floating-point literal. Integers may carry a 0x, 0o or 0b base
+// prefix, and digits may be separated by underscores (1_000_000). A decimal literal becomes a FLOAT
+// when its digits are followed by a fraction (a dot and at least one digit) or an exponent (e or E,
+// an optional sign and digits).
+//
+// A literal extends over every letter and digit that directly follows it, so malformed literals
+// such as 0b102, 0x or 12abc come out as a single ILLEGAL token with an error describing the
+// problem, instead of being split into surprising tokens.
+func (l *Lexer) readNumber() token.Token {
+	pos := l.position()
+	var tokenType token.TokenType = token.INT
+
+	if l.currentChar == '0' && isBasePrefix(l.peekChar()) {
+		l.readChar()
+		l.readChar()
+	} else {
+		l.readDigits()
+		if l.currentChar == '.' && isDigit(l.peekChar()) {
+			tokenType = token.FLOAT
+			l.readChar()
+			l.readDigits()
+		}
+		if l.currentChar == 'e' || l.currentChar == 'E' {
+			tokenType = token.FLOAT
+			l.readChar()
+			if l.currentChar == '+' || l.currentChar == '-' {
+				l.readChar()
+			}
+			l.readDigits()
+		}
+	}
+
+	for isLetter(l.currentChar) || unicode.IsDigit(l.currentChar) {
+		l.readChar()
+	}
+
+	literal := l.slice(pos.Offset, l.currentPos)
+	if msg := checkNumber(literal); msg != "" {
+		l.addError(pos, msg)
+		return token.Token{Type: token.ILLEGAL, Literal: literal}
+	}
+	return token.Token{Type: tokenType, Literal: literal}
+}
+
+// checkNumber returns a description of what is wrong with the number literal, or an empty string
+// if it is well formed. Values out of range are left for the parser to report. Literals without a
+// base prefix are decimal, even with leading zeros: 09 is valid, and octal takes an explicit 0o.
+func checkNumber(literal string) string {
+	base, name, digits := 10, "decimal", literal
+	if len(literal) > 1 && literal[0] == '0' && isBasePrefix(rune(literal[1])) {
+		switch literal[1] {
+		case 'x', 'X':
+			base, name = 16, "hexadecimal"
+		case 'o', 'O':
+			base, name = 8, "octal"
+		default:
+			base, name = 2, "binary"
+		}
+		digits = literal[2:]
+	}
+
+	const separatorError = "'_' must separate successive digits"
+	prev := '0' // treat the start of the digits as following a digit, as Go does after a base prefix
+	hasDigits, inExponent, hasExponentDigits := false, false, false
+	for _, ch := range digits {
+		switch {
+		case digitValue(ch) < base:
+			hasDigits = true
+			hasExponentDigits = inExponent
+		case ch == '_':
+			if digitValue(prev) >= base {
+				return separatorError
+			}
+		case base == 10 && ch == '.':
+			if prev == '_' {
+				return separatorError
+			}
+		case base == 10 && (ch == 'e' || ch == 'E') && !inExponent:
+			if prev == '_' {
+				return separatorError
+			}
+			inExponent = true
+		case (ch == '+' || ch == '-') && (prev == 'e' || prev == 'E'):
+		case isDigit(ch):
+			return fmt.Sprintf("invalid digit %q in %s literal", ch, name)
+		default:
+			return fmt.Sprintf("invalid character %q in %s literal", ch, name)
+		}
+		prev = ch
+	}
+
+	switch {
+	case !hasDigits:
+		return fmt.Sprintf("%s literal has no digits", name)
+	case inExponent && !hasExponentDigits:
+		return "exponent has no digits"
+	case prev == '_':
+		return separatorError
+	}
+	return ""
+}
+
+// readDigits advances the scanner past a run of decimal digits and digit separators.
+func (l *Lexer) readDigits() {
+	for isDigit(l.currentChar) || l.currentChar == '_' {
 		l.readChar()
 	}
-	return l.input[startPos:l.currentPos]
 }
 
-// readNumber scans a number from the input, capturing characters until a non-digit is encountered.
-func (l *Lexer) readNumber() string {
+// readString scans a part of a double-quoted string literal and decodes its escape sequences.
+// The part starts at the current character, either the opening quote or the "}" that closes an
+// interpolation, and runs up to the closing quote or to the "${" that opens an interpolation.
+// The token has type closed in the first case and type open in the second, in which case the lexer
+// enters the interpolation. A string without interpolations thus comes out as a single STRING token.
+//
+// The token is ILLEGAL, with the raw source text as its literal, if the string is unterminated or
+// the part contains an invalid escape sequence.
+func (l *Lexer) readString(closed, open token.TokenType) token.Token {
+	pos := l.position()
+	startPos := pos.Offset
+	valid := true
+
+	// The literal is a slice of the input, unless escape sequences have to be decoded. The text
+	// is then accumulated in value, one run of plain characters at a time.
+	var value strings.Builder
+	runStart := 0
+	literal := func() string {
+		if value.Len() == 0 {
+			return l.slice(runStart, l.currentPos)
+		}
+		value.WriteString(l.slice(runStart, l.currentPos))
+		return value.String()
+	}
+
+	l.readChar() // skip the opening quote or brace
+	runStart = l.currentPos
+	for l.currentChar != '"' {
+		if l.atEnd() {
+			l.addError(pos, "unterminated string literal")
+			return token.Token{Type: token.ILLEGAL, Literal: l.slice(startPos, l.currentPos)}
+		}
+		if l.currentChar == '$' && l.peekChar() == '{' {
+			text := literal()
+			l.readChar()
+			l.readChar() // skip "${"
+			l.interpolations = append(l.interpolations, 0)
+			if !valid {
+				return token.Token{Type: token.ILLEGAL, Literal: l.slice(startPos, l.currentPos)}
+			}
+			return token.Token{Type: open, Literal: text}
+		}
+		if l.currentChar != '\\' {
+			l.readChar()
+			continue
+		}
+
+		value.WriteString(l.slice(runStart, l.currentPos))
+		escapePos := l.position()
+		l.readChar()
+		if l.readEscape(&value) {
+			l.readChar()
+		} else {
+			// Include the offending character in the error, unless it closes the string,
+			// ends the input or starts another escape sequence.
+			if l.currentChar != '"' && !l.atEnd() && l.currentChar != '\\' {
+				l.readChar()
+			}
+			l.addError(escapePos, fmt.Sprintf("invalid escape sequence %s", l.slice(escapePos.Offset, l.currentPos)))
+			valid = false
+		}
+		runStart = l.currentPos
+	}
+	text := literal()
+	l.readChar() // skip the closing quote
+
+	if !valid {
+		return token.Token{Type: token.ILLEGAL, Literal: l.slice(startPos, l.currentPos)}
+	}
+	return token.Token{Type: closed, Literal: text}
+}
+
+// readRawString scans a string literal enclosed in backquotes. Raw strings may span several lines
+// and have neither escape sequences nor interpolations: the literal is the text between the quotes.
+func (l *Lexer) readRawString() token.Token {
+	pos := l.position()
+
+	l.readChar() // skip the opening backquote
 	startPos := l.currentPos
-	for isDigit(l.currentChar) {
+	for l.currentChar != '`' {
+		if l.atEnd() {
+			l.addError(pos, "unterminated raw string literal")
+			return token.Token{Type: token.ILLEGAL, Literal: l.slice(pos.Offset, l.currentPos)}
+		}
 		l.readChar()
 	}
-	return l.input[startPos:l.currentPos]
+	literal := l.slice(startPos, l.currentPos)
+	l.readChar() // skip the closing backquote
+
+	return token.Token{Type: token.STRING, Literal: literal}
+}
+
+// readEscape decodes the escape sequence whose first character, following the backslash,
+// is the current character. On success the current character is the last one of the sequence.
+func (l *Lexer) readEscape(value *strings.Builder) bool {
+	switch l.currentChar {
+	case 'n':
+		value.WriteByte('\n')
+	case 't':
+		value.WriteByte('\t')
+	case '"':
+		value.WriteByte('"')
+	case '\\':
+		value.WriteByte('\\')
+	case '$':
+		value.WriteByte('$')
+	case 'u':
+		// \u{...} holds between one and six hexadecimal digits naming a Unicode code point.
+		if l.peekChar() != '{' {
+			return false
+		}
+		l.readChar()
+		l.readChar()
+		startPos := l.currentPos
+		for isHexDigit(l.currentChar) {
+			l.readChar()
+		}
+		digits := l.slice(startPos, l.currentPos)
+		if l.currentChar != '}' || len(digits) == 0 || len(digits) > 6 {
+			return false
+		}
+		code, err := strconv.ParseUint(digits, 16, 32)
+		if err != nil || !utf8.ValidRune(rune(code)) {
+			return false
+		}
+		value.WriteRune(rune(code))
+	default:
+		return false
+	}
+	return true
 }
 
 // Utility functions
 
-// isDigit checks if the given byte is a valid digit.
-func isDigit(b byte) bool {
-	return '0' <= b && b <= '9'
+// isWhitespace checks if the given character is a space, tab or line break.
+func isWhitespace(ch rune) bool {
+	return ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r'
+}
+
+// digitValue returns the value of the given character as a hexadecimal digit,
+// or 16 if it is not one.
+func digitValue(ch rune) int {
+	switch {
+	case '0' <= ch && ch <= '9':
+		return int(ch - '0')
+	case 'a' <= ch && ch <= 'f':
+		return int(ch - 'a' + 10)
+	case 'A' <= ch && ch <= 'F':
+		return int(ch - 'A' + 10)
+	}
+	return 16
+}
+
+// isDigit checks if the given character is an ASCII decimal digit.
+func isDigit(ch rune) bool {
+	return '0' <= ch && ch <= '9'
+}
+
+// isHexDigit checks if the given character is a valid hexadecimal digit.
+func isHexDigit(ch rune) bool {
+	return isDigit(ch) || 'a' <= ch && ch <= 'f' || 'A' <= ch && ch <= 'F'
+}
+
+// isBasePrefix checks if the given character, following a leading 0, introduces a hexadecimal,
+// octal or binary integer literal.
+func isBasePrefix(ch rune) bool {
+	switch ch {
+	case 'x', 'X', 'o', 'O', 'b', 'B':
+		return true
+	}
+	return false
 }
 
-// isLetter checks if the given byte corresponds to a valid letter for identifiers in Monkey.
-func isLetter(b byte) bool {
-	return 'a' <= b && b <= 'z' || 'A' <= b && b <= 'Z' || b == '_'
+// isLetter checks if the given character is a valid letter for identifiers in Monkey:
+// any Unicode letter or the underscore.
+func isLetter(ch rune) bool {
+	return unicode.IsLetter(ch) || ch == '_'
 }
@@ -3,6 +3,7 @@
 package lexer
 
 import (
+	"strings"
 	"testing"
 
 	"monkey/token"
@@ -19,7 +20,7 @@ func TestLexerInitialization(t *testing.T) {
 	}
 
 	// Check the initial value of currentChar
-	if lexer.currentChar != input[0] {
+	if lexer.currentChar != rune(input[0]) {
 		t.Fatalf("Expected current char to be '%c', got '%c'", input[0], lexer.currentChar)
 	}
 
@@ -87,7 +88,7 @@ func runNextTokenTests(tests []tokenTest, lexer *Lexer, t *testing.T) {
 
 // TestNextToken_SimpleTokens tests the lexer's ability to tokenize simple one-character tokens.
 func TestNextToken_SimpleTokens(t *testing.T) {
-	input := "=+(){},;-/*<>!"
+	input := "=+(){}[],;:-*/<>!"
 	lexer := New(input)
 
 	tests := []tokenTest{
@@ -97,11 +98,14 @@ func TestNextToken_SimpleTokens(t *testing.T) {
 		{token.RPAREN, ")"},
 		{token.LBRACE, "{"},
 		{token.RBRACE, "}"},
+		{token.LBRACKET, "["},
+		{token.RBRACKET, "]"},
 		{token.COMMA, ","},
 		{token.SEMICOLON, ";"},
+		{token.COLON, ":"},
 		{token.MINUS, "-"},
-		{token.SLASH, "/"},
 		{token.ASTERISK, "*"},
+		{token.SLASH, "/"},
 		{token.LT, "<"},
 		{token.GT, ">"},
 		{token.BANG, "!"},
@@ -199,3 +203,471 @@ return false;
 
 	runNextTokenTests(tests, lexer, t)
 }
+
+// TestNextToken_LoopKeywords tests the keywords of while and for loops.
+func TestNextToken_LoopKeywords(t *testing.T) {
+	input := `while for in break continue index`
+	lexer := New(input)
+
+	tests := []tokenTest{
+		{token.WHILE, "while"},
+		{token.FOR, "for"},
+		{token.IN, "in"},
+		{token.BREAK, "break"},
+		{token.CONTINUE, "continue"},
+		{token.IDENT, "index"},
+		{token.EOF, ""},
+	}
+
+	runNextTokenTests(tests, lexer, t)
+}
+
+// TestNextToken_Positions tests that every token is stamped with its line, column and offset.
+func TestNextToken_Positions(t *testing.T) {
+	input := "let x = 5;\n  x + 10;\n\n}"
+	lexer := New(input, WithFilename("main.mk"))
+
+	tests := []token.Position{
+		{Filename: "main.mk", Offset: 0, Line: 1, Column: 1},  // let
+		{Filename: "main.mk", Offset: 4, Line: 1, Column: 5},  // x
+		{Filename: "main.mk", Offset: 6, Line: 1, Column: 7},  // =
+		{Filename: "main.mk", Offset: 8, Line: 1, Column: 9},  // 5
+		{Filename: "main.mk", Offset: 9, Line: 1, Column: 10}, // ;
+		{Filename: "main.mk", Offset: 13, Line: 2, Column: 3}, // x
+		{Filename: "main.mk", Offset: 15, Line: 2, Column: 5}, // +
+		{Filename: "main.mk", Offset: 17, Line: 2, Column: 7}, // 10
+		{Filename: "main.mk", Offset: 19, Line: 2, Column: 9}, // ;
+		{Filename: "main.mk", Offset: 22, Line: 4, Column: 1}, // }
+		{Filename: "main.mk", Offset: 23, Line: 4, Column: 2}, // EOF
+	}
+
+	for i, expected := range tests {
+		tok := lexer.NextToken()
+		if tok.Pos != expected {
+			t.Fatalf("tests[%d] - position of %q wrong. expected=%s (offset %d), got=%s (offset %d)",
+				i, tok.Literal, expected, expected.Offset, tok.Pos, tok.Pos.Offset)
+		}
+	}
+}
+
+// TestPositionString tests the textual representation of token positions.
+func TestPositionString(t *testing.T) {
+	tests := []struct {
+		pos      token.Position
+		expected string
+	}{
+		{token.Position{Filename: "main.mk", Line: 3, Column: 7}, "main.mk:3:7"},
+		{token.Position{Line: 1, Column: 1}, "1:1"},
+		{token.Position{}, "-"},
+	}
+
+	for _, test := range tests {
+		if got := test.pos.String(); got != test.expected {
+			t.Errorf("expected=%q, got=%q", test.expected, got)
+		}
+	}
+}
+
+// TestNextToken_Strings tests the lexer's handling of string literals and their escape sequences.
+func TestNextToken_Strings(t *testing.T) {
+	input := `"foobar" "foo bar" "" "a\nb\tc" "say \"hi\"" "back\\slash" "\u{48}\u{e9}\u{1F412}"`
+	lexer := New(input)
+
+	tests := []tokenTest{
+		{token.STRING, "foobar"},
+		{token.STRING, "foo bar"},
+		{token.STRING, ""},
+		{token.STRING, "a\nb\tc"},
+		{token.STRING, `say "hi"`},
+		{token.STRING, `back\slash`},
+		{token.STRING, "Hé🐒"},
+		{token.EOF, ""},
+	}
+
+	runNextTokenTests(tests, lexer, t)
+}
+
+// TestNextToken_RawStrings tests that backquoted strings span lines and keep their text as is.
+func TestNextToken_RawStrings(t *testing.T) {
+	input := "`multi\nline` `no \\n ${escapes}` ``;"
+	lexer := New(input)
+
+	tests := []tokenTest{
+		{token.STRING, "multi\nline"},
+		{token.STRING, `no \n ${escapes}`},
+		{token.STRING, ""},
+		{token.SEMICOLON, ";"},
+		{token.EOF, ""},
+	}
+
+	runNextTokenTests(tests, lexer, t)
+}
+
+// TestNextToken_InterpolatedStrings tests that the expressions embedded in a string are lexed as
+// tokens of their own between the parts of the string, including nested strings and braces.
+func TestNextToken_InterpolatedStrings(t *testing.T) {
+	input := `"hello ${name}!" "${a}${ {b} }" "x ${ "in ${c} ner" } y" "\${not} $ {}"`
+	lexer := New(input)
+
+	tests := []tokenTest{
+		{token.STRING_START, "hello "},
+		{token.IDENT, "name"},
+		{token.STRING_END, "!"},
+		{token.STRING_START, ""},
+		{token.IDENT, "a"},
+		{token.STRING_MIDDLE, ""},
+		{token.LBRACE, "{"},
+		{token.IDENT, "b"},
+		{token.RBRACE, "}"},
+		{token.STRING_END, ""},
+		{token.STRING_START, "x "},
+		{token.STRING_START, "in "},
+		{token.IDENT, "c"},
+		{token.STRING_END, " ner"},
+		{token.STRING_END, " y"},
+		{token.STRING, "${not} $ {}"},
+		{token.EOF, ""},
+	}
+
+	runNextTokenTests(tests, lexer, t)
+}
+
+// TestNextToken_InterpolatedStringRaw tests that the parts of an interpolated string carry their
+// delimiters in their raw text.
+func TestNextToken_InterpolatedStringRaw(t *testing.T) {
+	tokens, err := Tokenize(`"a${x}b${ y }c"`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []string{`"a${`, "x", "}b${", "y", `}c"`, ""}
+	if len(tokens) != len(expected) {
+		t.Fatalf("expected %d tokens, got %d", len(expected), len(tokens))
+	}
+	for i, raw := range expected {
+		if tokens[i].Raw != raw {
+			t.Errorf("tokens[%d] - raw wrong. expected=%q, got=%q", i, raw, tokens[i].Raw)
+		}
+	}
+}
+
+// TestNextToken_InvalidStrings tests that malformed string literals produce a single ILLEGAL token
+// holding their raw source text.
+func TestNextToken_InvalidStrings(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []tokenTest
+	}{
+		{`"unterminated`, []tokenTest{{token.ILLEGAL, `"unterminated`}, {token.EOF, ""}}},
+		{`"bad \q escape";`, []tokenTest{{token.ILLEGAL, `"bad \q escape"`}, {token.SEMICOLON, ";"}, {token.EOF, ""}}},
+		{`"\u{110000}"`, []tokenTest{{token.ILLEGAL, `"\u{110000}"`}, {token.EOF, ""}}},
+		{`"\u{}"`, []tokenTest{{token.ILLEGAL, `"\u{}"`}, {token.EOF, ""}}},
+		{`"\u41"`, []tokenTest{{token.ILLEGAL, `"\u41"`}, {token.EOF, ""}}},
+		{`"trailing \`, []tokenTest{{token.ILLEGAL, `"trailing \`}, {token.EOF, ""}}},
+		{"`unterminated", []tokenTest{{token.ILLEGAL, "`unterminated"}, {token.EOF, ""}}},
+		{`"a ${b`, []tokenTest{{token.STRING_START, "a "}, {token.IDENT, "b"}, {token.EOF, ""}}},
+		{`"a ${b} c`, []tokenTest{{token.STRING_START, "a "}, {token.IDENT, "b"}, {token.ILLEGAL, "} c"}, {token.EOF, ""}}},
+		{`"\q ${b}"`, []tokenTest{{token.ILLEGAL, `"\q ${`}, {token.IDENT, "b"}, {token.STRING_END, ""}, {token.EOF, ""}}},
+	}
+
+	for _, test := range tests {
+		runNextTokenTests(test.expected, New(test.input), t)
+	}
+}
+
+// TestNextToken_SkipsComments tests that line and block comments, including nested ones, are skipped by default.
+func TestNextToken_SkipsComments(t *testing.T) {
+	input := `// leading comment
+let x = 5; // trailing comment
+/* block /* nested */ still comment */ x / 2;
+/**/ x//
+`
+	lexer := New(input)
+
+	tests := []tokenTest{
+		{token.LET, "let"},
+		{token.IDENT, "x"},
+		{token.ASSIGN, "="},
+		{token.INT, "5"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "x"},
+		{token.SLASH, "/"},
+		{token.INT, "2"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "x"},
+		{token.EOF, ""},
+	}
+
+	runNextTokenTests(tests, lexer, t)
+}
+
+// TestNextToken_EmitsComments tests that the WithComments option turns comments into COMMENT tokens.
+func TestNextToken_EmitsComments(t *testing.T) {
+	input := "// note\nx /* a /* b */ c */ y"
+	lexer := New(input, WithComments())
+
+	tests := []tokenTest{
+		{token.COMMENT, "// note"},
+		{token.IDENT, "x"},
+		{token.COMMENT, "/* a /* b */ c */"},
+		{token.IDENT, "y"},
+		{token.EOF, ""},
+	}
+
+	runNextTokenTests(tests, lexer, t)
+}
+
+// TestNextToken_UnterminatedBlockComment tests that an unterminated block comment yields an ILLEGAL token.
+func TestNextToken_UnterminatedBlockComment(t *testing.T) {
+	input := "x /* open /* nested */ never closed"
+	lexer := New(input)
+
+	tests := []tokenTest{
+		{token.IDENT, "x"},
+		{token.ILLEGAL, "/* open /* nested */ never closed"},
+		{token.EOF, ""},
+	}
+
+	runNextTokenTests(tests, lexer, t)
+}
+
+// TestNextToken_Numbers tests the lexer's handling of integer and floating-point literals.
+func TestNextToken_Numbers(t *testing.T) {
+	input := "42 3.14 0.5 1.5e-3 2E10 6e+2 7. 8.x 1e+"
+	lexer := New(input)
+
+	tests := []tokenTest{
+		{token.INT, "42"},
+		{token.FLOAT, "3.14"},
+		{token.FLOAT, "0.5"},
+		{token.FLOAT, "1.5e-3"},
+		{token.FLOAT, "2E10"},
+		{token.FLOAT, "6e+2"},
+		{token.INT, "7"},
+		{token.ILLEGAL, "."},
+		{token.INT, "8"},
+		{token.ILLEGAL, "."},
+		{token.IDENT, "x"},
+		{token.ILLEGAL, "1e+"},
+		{token.EOF, ""},
+	}
+
+	runNextTokenTests(tests, lexer, t)
+}
+
+// TestNextToken_Unicode tests that identifiers may use Unicode letters and digits, and that
+// an illegal multibyte character is reported as a single ILLEGAL token.
+func TestNextToken_Unicode(t *testing.T) {
+	input := "let café = 1; let 数字2 = x€y; \xff"
+	lexer := New(input)
+
+	tests := []tokenTest{
+		{token.LET, "let"},
+		{token.IDENT, "café"},
+		{token.ASSIGN, "="},
+		{token.INT, "1"},
+		{token.SEMICOLON, ";"},
+		{token.LET, "let"},
+		{token.IDENT, "数字2"},
+		{token.ASSIGN, "="},
+		{token.IDENT, "x"},
+		{token.ILLEGAL, "€"},
+		{token.IDENT, "y"},
+		{token.SEMICOLON, ";"},
+		{token.ILLEGAL, "\xff"},
+		{token.EOF, ""},
+	}
+
+	runNextTokenTests(tests, lexer, t)
+}
+
+// TestNextToken_UnicodeColumns tests that columns count characters while offsets count bytes.
+func TestNextToken_UnicodeColumns(t *testing.T) {
+	lexer := New(`"héllo" ünï x`)
+
+	tests := []token.Position{
+		{Offset: 0, Line: 1, Column: 1},
+		{Offset: 9, Line: 1, Column: 9},
+		{Offset: 15, Line: 1, Column: 13},
+	}
+
+	for i, expected := range tests {
+		tok := lexer.NextToken()
+		if tok.Pos != expected {
+			t.Fatalf("tests[%d] - position of %q wrong. expected=%s (offset %d), got=%s (offset %d)",
+				i, tok.Literal, expected, expected.Offset, tok.Pos, tok.Pos.Offset)
+		}
+	}
+}
+
+// TestNextToken_IntegerBases tests based integer literals and digit separators, and that malformed
+// literals are kept together as a single ILLEGAL token.
+func TestNextToken_IntegerBases(t *testing.T) {
+	input := "0xFF 0o17 0B1010 1_000_000 0x_dead_BEEF 1_000.5 0b102 0x 12abc 3.5kg 0;"
+	lexer := New(input)
+
+	tests := []tokenTest{
+		{token.INT, "0xFF"},
+		{token.INT, "0o17"},
+		{token.INT, "0B1010"},
+		{token.INT, "1_000_000"},
+		{token.INT, "0x_dead_BEEF"},
+		{token.FLOAT, "1_000.5"},
+		{token.ILLEGAL, "0b102"},
+		{token.ILLEGAL, "0x"},
+		{token.ILLEGAL, "12abc"},
+		{token.ILLEGAL, "3.5kg"},
+		{token.INT, "0"},
+		{token.SEMICOLON, ";"},
+		{token.EOF, ""},
+	}
+
+	runNextTokenTests(tests, lexer, t)
+}
+
+// TestNextToken_Operators tests the lexer's handling of comparison, logical, arithmetic and bitwise operators.
+func TestNextToken_Operators(t *testing.T) {
+	input := "<= >= < > && || & | ^ << >> % ** * <<= >>> &&& ||| ***"
+	lexer := New(input)
+
+	tests := []tokenTest{
+		{token.LT_EQ, "<="},
+		{token.GT_EQ, ">="},
+		{token.LT, "<"},
+		{token.GT, ">"},
+		{token.AND, "&&"},
+		{token.OR, "||"},
+		{token.AMPERSAND, "&"},
+		{token.PIPE, "|"},
+		{token.CARET, "^"},
+		{token.SHIFT_LEFT, "<<"},
+		{token.SHIFT_RIGHT, ">>"},
+		{token.PERCENT, "%"},
+		{token.POWER, "**"},
+		{token.ASTERISK, "*"},
+		{token.SHIFT_LEFT, "<<"},
+		{token.ASSIGN, "="},
+		{token.SHIFT_RIGHT, ">>"},
+		{token.GT, ">"},
+		{token.AND, "&&"},
+		{token.AMPERSAND, "&"},
+		{token.OR, "||"},
+		{token.PIPE, "|"},
+		{token.POWER, "**"},
+		{token.ASTERISK, "*"},
+		{token.EOF, ""},
+	}
+
+	runNextTokenTests(tests, lexer, t)
+}
+
+// TestNextToken_AssignmentOperators tests the lexer's handling of assignment and compound assignment operators.
+func TestNextToken_AssignmentOperators(t *testing.T) {
+	input := "= += -= *= /= %= == **= +=="
+	lexer := New(input)
+
+	tests := []tokenTest{
+		{token.ASSIGN, "="},
+		{token.PLUS_ASSIGN, "+="},
+		{token.MINUS_ASSIGN, "-="},
+		{token.ASTERISK_ASSIGN, "*="},
+		{token.SLASH_ASSIGN, "/="},
+		{token.PERCENT_ASSIGN, "%="},
+		{token.EQ, "=="},
+		{token.POWER, "**"},
+		{token.ASSIGN, "="},
+		{token.PLUS_ASSIGN, "+="},
+		{token.ASSIGN, "="},
+		{token.EOF, ""},
+	}
+
+	runNextTokenTests(tests, lexer, t)
+}
+
+// TestNextToken_Trivia tests how a lossless lexer splits whitespace and comments into
+// leading and trailing trivia.
+func TestNextToken_Trivia(t *testing.T) {
+	input := "// header\nlet x = 5; // five\n\n  /* doc */ x\t/* open"
+	lexer := New(input, WithTrivia())
+
+	tests := []struct {
+		expectedType     token.TokenType
+		expectedRaw      string
+		expectedLeading  string
+		expectedTrailing string
+	}{
+		{token.LET, "let", "// header\n", " "},
+		{token.IDENT, "x", "", " "},
+		{token.ASSIGN, "=", "", " "},
+		{token.INT, "5", "", ""},
+		{token.SEMICOLON, ";", "", " // five"},
+		{token.IDENT, "x", "\n\n  /* doc */ ", "\t"},
+		{token.ILLEGAL, "/* open", "", ""},
+		{token.EOF, "", "", ""},
+	}
+
+	for i, test := range tests {
+		tok := lexer.NextToken()
+		if tok.Type != test.expectedType || tok.Raw != test.expectedRaw {
+			t.Fatalf("tests[%d] - token wrong. expected=%q %q, got=%q %q", i, test.expectedType, test.expectedRaw, tok.Type, tok.Raw)
+		}
+		if tok.Leading != test.expectedLeading {
+			t.Errorf("tests[%d] - leading trivia wrong. expected=%q, got=%q", i, test.expectedLeading, tok.Leading)
+		}
+		if tok.Trailing != test.expectedTrailing {
+			t.Errorf("tests[%d] - trailing trivia wrong. expected=%q, got=%q", i, test.expectedTrailing, tok.Trailing)
+		}
+	}
+}
+
+// TestNextToken_LosslessRoundTrip tests that concatenating the tokens of a lossless lexer, trivia
+// included, reproduces the input byte for byte.
+func TestNextToken_LosslessRoundTrip(t *testing.T) {
+	inputs := []string{
+		"",
+		"   \n\t ",
+		"let x = 5;\r\nlet y = \"a\\tb\\u{41}\"; // trailing\r\n",
+		"/* a /* nested */ comment */ fn(x) { x ** 2 } /* unterminated",
+		"let café = 0x_FF; € @ \xff 12abc\n// last line without newline",
+		"x /* spans\nlines */ y\n",
+		"let a = 1;\x00let b = \"\x00\"; // \x00\n/* \x00 */ `\x00`",
+	}
+
+	for _, input := range inputs {
+		for _, opts := range [][]Option{{WithTrivia()}, {WithTrivia(), WithComments()}} {
+			lexer := New(input, opts...)
+			var out strings.Builder
+			for {
+				tok := lexer.NextToken()
+				out.WriteString(tok.Source())
+				if tok.Type == token.EOF {
+					break
+				}
+			}
+			if out.String() != input {
+				t.Errorf("round trip failed. expected=%q, got=%q", input, out.String())
+			}
+		}
+	}
+}
+
+// benchmarkInput is a large Monkey source file, about 1 MB, made of typical statements.
+var benchmarkInput = strings.Repeat(`// compute a few things
+let total = 0x_FF + 1_000 * (count - 1) / 3.5e2;
+let greeting = "hello, ${name}! you have ${count} new messages\n";
+if (total >= 10 && !done || retries != 3) { return total ** 2 % 7; } else { return false; }
+/* a block comment
+   spanning lines */
+let shifted = (mask << 4) | (flags & 0b1010) ^ value >> 1;
+`, 3000)
+
+// BenchmarkNextToken measures the throughput of the lexer on a large input.
+func BenchmarkNextToken(b *testing.B) {
+	b.SetBytes(int64(len(benchmarkInput)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		lexer := New(benchmarkInput)
+		for tok := lexer.NextToken(); tok.Type != token.EOF; tok = lexer.NextToken() {
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package lexer
+
+import "io"
+
+// defaultChunkSize is the number of bytes a streaming lexer requests from its reader at a time.
+const defaultChunkSize = 4096
+
+// NewReader returns a Lexer that reads its input incrementally from r. It produces the same tokens
+// as New does for the full contents of r, but only keeps a bounded window of the input in memory:
+// the bytes of the token being scanned plus at most one chunk of lookahead.
+func NewReader(r io.Reader, opts ...Option) *Lexer {
+	return newReaderSize(r, defaultChunkSize, opts...)
+}
+
+// newReaderSize returns a streaming Lexer that reads chunks of the given size from r.
+func newReaderSize(r io.Reader, size int, opts ...Option) *Lexer {
+	return newLexer(&Lexer{reader: r, chunk: make([]byte, size)}, opts)
+}
+
+// Err returns the first error, other than io.EOF, encountered while reading the input.
+// The lexer treats such an error as the end of the input.
+func (l *Lexer) Err() error {
+	return l.readErr
+}
+
+// fill drops the part of the window that the current token can no longer refer to and appends
+// the next chunk read from the reader. Once the reader is exhausted or fails, it is released.
+func (l *Lexer) fill() {
+	n, err := l.reader.Read(l.chunk)
+	if n > 0 {
+		keep := l.tokenStart - l.base
+		l.input = l.input[keep:] + string(l.chunk[:n])
+		l.base = l.tokenStart
+	}
+	if err != nil {
+		if err != io.EOF {
+			l.readErr = err
+		}
+		l.reader = nil
+		l.chunk = nil
+	}
+}
@@ -0,0 +1,100 @@
+package lexer
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"monkey/token"
+)
+
+// readerTestInput exercises every kind of token, including multibyte characters that end up split
+// across chunk boundaries.
+const readerTestInput = `// greeting
+let café = "héllo\t\u{1F412} wörld";
+/* nested /* block */ comment */
+let add = fn(x, y) { x + y; };
+if (0xFF != 1_000) { return 3.14e-2; } else { return false; }
+let s = "a ${ if (x) { "b${y}\${" } } c" + ` + "`raw\n ${string}`" + `;
+€ 12abc "unterminated`
+
+// collectTokens returns every token produced by the lexer, up to and including EOF.
+func collectTokens(l *Lexer) []token.Token {
+	var tokens []token.Token
+	for {
+		tok := l.NextToken()
+		tokens = append(tokens, tok)
+		if tok.Type == token.EOF {
+			return tokens
+		}
+	}
+}
+
+// TestNewReader_MatchesNew tests that the streaming lexer produces exactly the tokens of the string
+// lexer, whatever the size of the chunks read from the underlying reader.
+func TestNewReader_MatchesNew(t *testing.T) {
+	for _, opts := range [][]Option{{}, {WithComments()}, {WithTrivia()}} {
+		expected := collectTokens(New(readerTestInput, opts...))
+
+		for _, size := range []int{1, 2, 3, 5, 16, defaultChunkSize} {
+			got := collectTokens(newReaderSize(strings.NewReader(readerTestInput), size, opts...))
+
+			if len(got) != len(expected) {
+				t.Fatalf("chunk size %d - expected %d tokens, got %d", size, len(expected), len(got))
+			}
+			for i := range expected {
+				if got[i] != expected[i] {
+					t.Fatalf("chunk size %d - tokens[%d] wrong. expected=%+v, got=%+v", size, i, expected[i], got[i])
+				}
+			}
+		}
+	}
+}
+
+// TestNewReader_BoundedWindow tests that the streaming lexer does not accumulate its whole input.
+func TestNewReader_BoundedWindow(t *testing.T) {
+	const chunkSize = 64
+	input := strings.Repeat("let x = 12345 + y; // some filler\n", 2000)
+	l := newReaderSize(strings.NewReader(input), chunkSize)
+
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		if len(l.input) > 2*chunkSize {
+			t.Fatalf("window grew to %d bytes at offset %d", len(l.input), tok.Pos.Offset)
+		}
+	}
+}
+
+// TestNewReader_LongToken tests that a token longer than a chunk is kept whole.
+func TestNewReader_LongToken(t *testing.T) {
+	long := strings.Repeat("a", 100)
+	l := newReaderSize(strings.NewReader(`"`+long+`" `+long), 8)
+
+	tests := []tokenTest{
+		{token.STRING, long},
+		{token.IDENT, long},
+		{token.EOF, ""},
+	}
+
+	runNextTokenTests(tests, l, t)
+}
+
+// TestNewReader_ReadError tests that a failing reader ends the input and reports its error.
+func TestNewReader_ReadError(t *testing.T) {
+	errBroken := errors.New("broken pipe")
+	reader := io.MultiReader(strings.NewReader("let x"), iotest.ErrReader(errBroken))
+	l := NewReader(reader)
+
+	tests := []tokenTest{
+		{token.LET, "let"},
+		{token.IDENT, "x"},
+		{token.EOF, ""},
+	}
+
+	runNextTokenTests(tests, l, t)
+
+	if !errors.Is(l.Err(), errBroken) {
+		t.Fatalf("expected Err() to return %v, got %v", errBroken, l.Err())
+	}
+}
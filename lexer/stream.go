@@ -0,0 +1,112 @@
+package lexer
+
+import (
+	"context"
+	"fmt"
+
+	"monkey/token"
+)
+
+// ErrorList is a list of lexical errors, usable as a single error value.
+type ErrorList []LexError
+
+// Error describes the first error of the list and how many more follow it.
+func (list ErrorList) Error() string {
+	switch len(list) {
+	case 0:
+		return "no errors"
+	case 1:
+		return list[0].Error()
+	}
+	return fmt.Sprintf("%s (and %d more errors)", list[0], len(list)-1)
+}
+
+// Tokenize lexes src in one go and returns all of its tokens, the last one being EOF.
+// If the source contains lexical errors, the returned error is an ErrorList holding all of them.
+func Tokenize(src string, opts ...Option) ([]token.Token, error) {
+	l := New(src, opts...)
+
+	var tokens []token.Token
+	for {
+		tok := l.NextToken()
+		tokens = append(tokens, tok)
+		if tok.Type == token.EOF {
+			break
+		}
+	}
+
+	if len(l.Errors()) > 0 {
+		return tokens, ErrorList(l.Errors())
+	}
+	return tokens, nil
+}
+
+// Stream runs a Lexer on its own goroutine and delivers the tokens over a channel, so that a
+// consumer such as the parser can work while the rest of the input is still being lexed.
+// A Stream has a NextToken method, which lets it stand in for a Lexer as the parser's token source.
+type Stream struct {
+	lexer  *Lexer
+	tokens chan token.Token
+	eof    token.Token
+	err    error
+}
+
+// NewStream starts lexing with l on a new goroutine, which runs at most buffer tokens ahead of the
+// consumer. The goroutine stops once it has delivered the EOF token or when ctx is cancelled;
+// consumers that stop reading early must cancel ctx to release it.
+func NewStream(ctx context.Context, l *Lexer, buffer int) *Stream {
+	s := &Stream{
+		lexer:  l,
+		tokens: make(chan token.Token, buffer),
+		eof:    token.Token{Type: token.EOF},
+	}
+	go s.run(ctx)
+	return s
+}
+
+// run lexes tokens and sends them on the channel until EOF or cancellation, then closes the channel.
+func (s *Stream) run(ctx context.Context) {
+	defer close(s.tokens)
+	for {
+		tok := s.lexer.NextToken()
+		select {
+		case s.tokens <- tok:
+		case <-ctx.Done():
+			s.err = ctx.Err()
+			return
+		}
+		if tok.Type == token.EOF {
+			return
+		}
+	}
+}
+
+// Tokens returns the channel on which tokens are delivered. It is closed after the EOF token,
+// or early if the stream is cancelled.
+func (s *Stream) Tokens() <-chan token.Token {
+	return s.tokens
+}
+
+// NextToken returns the next token of the stream, blocking until it is available. Once the stream
+// has ended, whether at the end of the input or through cancellation, it keeps returning EOF.
+func (s *Stream) NextToken() token.Token {
+	tok, ok := <-s.tokens
+	if !ok {
+		return s.eof
+	}
+	if tok.Type == token.EOF {
+		s.eof = tok
+	}
+	return tok
+}
+
+// Err returns the context's error if the stream was cancelled before reaching the end of the input.
+// It must only be called once the stream has ended.
+func (s *Stream) Err() error {
+	return s.err
+}
+
+// Errors returns the lexical errors found in the input. It must only be called once the stream has ended.
+func (s *Stream) Errors() []LexError {
+	return s.lexer.Errors()
+}
@@ -0,0 +1,108 @@
+package lexer
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"monkey/token"
+)
+
+// TestTokenize tests that Tokenize returns every token up to and including EOF.
+func TestTokenize(t *testing.T) {
+	tokens, err := Tokenize("let x = 5;")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []tokenTest{
+		{token.LET, "let"},
+		{token.IDENT, "x"},
+		{token.ASSIGN, "="},
+		{token.INT, "5"},
+		{token.SEMICOLON, ";"},
+		{token.EOF, ""},
+	}
+	if len(tokens) != len(expected) {
+		t.Fatalf("expected %d tokens, got %d", len(expected), len(tokens))
+	}
+	for i, test := range expected {
+		if tokens[i].Type != test.expectedType || tokens[i].Literal != test.expectedLiteral {
+			t.Errorf("tokens[%d] wrong. expected=%q %q, got=%q %q", i, test.expectedType, test.expectedLiteral, tokens[i].Type, tokens[i].Literal)
+		}
+	}
+}
+
+// TestTokenize_Errors tests that Tokenize reports every lexical error through an ErrorList.
+func TestTokenize_Errors(t *testing.T) {
+	tokens, err := Tokenize("let x = @; 0b12")
+	if len(tokens) != 7 {
+		t.Errorf("expected 7 tokens, got %d", len(tokens))
+	}
+
+	var list ErrorList
+	if !errors.As(err, &list) {
+		t.Fatalf("expected an ErrorList, got %T (%v)", err, err)
+	}
+	if len(list) != 2 {
+		t.Fatalf("expected 2 errors, got %d", len(list))
+	}
+	expected := `1:9: illegal character "@" (and 1 more errors)`
+	if err.Error() != expected {
+		t.Errorf("expected=%q, got=%q", expected, err.Error())
+	}
+}
+
+// TestStream_MatchesLexer tests that a Stream delivers exactly the tokens of the underlying lexer,
+// and keeps returning EOF once exhausted.
+func TestStream_MatchesLexer(t *testing.T) {
+	expected, _ := Tokenize(readerTestInput)
+
+	for _, buffer := range []int{0, 1, 64} {
+		stream := NewStream(context.Background(), New(readerTestInput), buffer)
+		for i, tok := range expected {
+			if got := stream.NextToken(); got != tok {
+				t.Fatalf("buffer %d - tokens[%d] wrong. expected=%+v, got=%+v", buffer, i, tok, got)
+			}
+		}
+		if got := stream.NextToken(); got != expected[len(expected)-1] {
+			t.Errorf("buffer %d - expected EOF after the end of the stream, got=%+v", buffer, got)
+		}
+		if stream.Err() != nil {
+			t.Errorf("buffer %d - unexpected error: %v", buffer, stream.Err())
+		}
+		if len(stream.Errors()) == 0 {
+			t.Errorf("buffer %d - expected the lexical errors of the input", buffer)
+		}
+	}
+}
+
+// TestStream_Cancel tests that cancelling the context stops the producer and closes the channel.
+func TestStream_Cancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	input := strings.Repeat("let x = 1;\n", 100000)
+	stream := NewStream(ctx, New(input), 4)
+
+	for i := 0; i < 10; i++ {
+		if tok := stream.NextToken(); tok.Type == token.EOF {
+			t.Fatalf("unexpected EOF at token %d", i)
+		}
+	}
+	cancel()
+
+	// Drain what was lexed ahead; the channel must then close well before the end of the input.
+	count := 0
+	for range stream.Tokens() {
+		count++
+	}
+	if count > 100 {
+		t.Errorf("producer kept running after cancellation: %d more tokens", count)
+	}
+	if !errors.Is(stream.Err(), context.Canceled) {
+		t.Errorf("expected Err() to return context.Canceled, got %v", stream.Err())
+	}
+	if tok := stream.NextToken(); tok.Type != token.EOF {
+		t.Errorf("expected EOF after cancellation, got %+v", tok)
+	}
+}
@@ -0,0 +1,60 @@
+package parser
+
+import (
+	"monkey/ast"
+	"monkey/token"
+)
+
+// PrefixParser parses the construct that starts with the current token of p, such as a dialect
+// keyword, and returns it. It is called with the parser on the first token of the construct, and
+// must leave it on the last one. After a syntax error, reported by one of the Parser methods
+// below, it returns nil.
+type PrefixParser func(p *Parser) ast.Expression
+
+// WithPrefix makes the parser call fn to parse the expressions, or statements, that start with a
+// token of type t. It gives a meaning to the keywords and prefix operators of a dialect with token
+// types of their own, and overrides the parsing of Monkey's own token types. Constructs without a
+// node of their own in package ast can be returned as an *ast.DialectExpression.
+func WithPrefix(t token.TokenType, fn PrefixParser) Option {
+	return func(p *Parser) {
+		p.registerPrefix(t, func() ast.Expression {
+			return fn(p)
+		})
+	}
+}
+
+// The methods below are meant for PrefixParser functions.
+
+// Current returns the token the parser is on.
+func (p *Parser) Current() token.Token {
+	return p.current
+}
+
+// Peek returns the token after the current one.
+func (p *Parser) Peek() token.Token {
+	return p.peek
+}
+
+// Advance moves the parser to the next token.
+func (p *Parser) Advance() {
+	p.advanceToken()
+}
+
+// ExpectPeek advances to the next token if it has type t. Otherwise it reports a syntax error and
+// returns false.
+func (p *Parser) ExpectPeek(t token.TokenType) bool {
+	return p.advanceIfPeekIs(t)
+}
+
+// ParseExpression parses the expression starting at the current token, whose operators bind more
+// tightly than the given precedence (use LOWEST for a whole expression). The parser is left on
+// its last token. It returns nil after a syntax error.
+func (p *Parser) ParseExpression(precedence int) ast.Expression {
+	return p.parseExpression(precedence)
+}
+
+// ParseBlock parses the block statement starting at the current token, an opening brace, and
+// leaves the parser on the closing one.
+func (p *Parser) ParseBlock() *ast.BlockStatement {
+	return p.parseBlockStatement()
+}
@@ -0,0 +1,80 @@
+package parser
+
+import (
+	"testing"
+
+	"monkey/ast"
+	"monkey/lexer"
+	"monkey/token"
+)
+
+// RULE is the token type of the rule keyword of the test dialect.
+var RULE = token.Register("RULE")
+
+// parseRule parses rule <name> { <body> }.
+func parseRule(p *Parser) ast.Expression {
+	rule := &ast.DialectExpression{Token: p.Current()}
+	p.Advance()
+	name := p.ParseExpression(LOWEST)
+	if name == nil || !p.ExpectPeek(token.LBRACE) {
+		return nil
+	}
+	rule.Arguments = []ast.Expression{name}
+	rule.Body = p.ParseBlock()
+	return rule
+}
+
+// TestParsingDialectKeywords verifies that dialect keywords with token types of their own are
+// parsed by the functions given with WithPrefix.
+func TestParsingDialectKeywords(t *testing.T) {
+	dialect := token.Dialect{
+		Keywords: map[string]token.TokenType{"rule": RULE, "when": token.IF},
+	}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`rule "adults" { when (age >= 18) { allow() } }`, `rule "adults" { when ((age >= 18)) { allow() } }`},
+		{`let r = rule a + b { x; y }; r`, `let r = rule (a + b) { x; y };r`},
+	}
+
+	for _, test := range tests {
+		program := parseDialect(t, dialect, test.input)
+		if program.String() != test.expected {
+			t.Errorf("expected=%q, got=%q", test.expected, program.String())
+		}
+	}
+
+	program := parseDialect(t, dialect, `rule "r" {}`)
+	statement := program.Statements[0].(*ast.ExpressionStatement)
+	rule, ok := statement.Expression.(*ast.DialectExpression)
+	if !ok {
+		t.Fatalf("statement.Expression is not *ast.DialectExpression. got=%T", statement.Expression)
+	}
+	if rule.Token.Type != RULE || len(rule.Arguments) != 1 || rule.Body == nil {
+		t.Errorf("rule parsed wrong. got=%+v", rule)
+	}
+
+	// Errors reported by the parse function are recovered from like any other.
+	p := New(lexer.New(`rule "r" x; let y = 1;`, lexer.WithDialect(dialect)), WithPrefix(RULE, parseRule))
+	program = p.ParseProgram()
+	if len(p.Errors()) != 1 || program.String() != "let y = 1;" {
+		t.Errorf("expected one error and the let statement kept, got %q and %q", p.Errors(), program.String())
+	}
+
+	// Without a parse function, the keyword is a syntax error.
+	p = New(lexer.New(`rule "r" {}`, lexer.WithDialect(dialect)), WithDialect(dialect))
+	p.ParseProgram()
+	if len(p.Errors()) == 0 {
+		t.Errorf("expected errors when parsing a dialect keyword without a parse function")
+	}
+}
+
+// parseDialect parses input with the rule keyword of the test dialect and checks for errors.
+func parseDialect(t *testing.T, dialect token.Dialect, input string) *ast.Program {
+	p := New(lexer.New(input, lexer.WithDialect(dialect)), WithDialect(dialect), WithPrefix(RULE, parseRule))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+	return program
+}
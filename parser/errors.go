@@ -0,0 +1,260 @@
+package parser
+
+import (
+	"fmt"
+	"unicode/utf8"
+
+	"monkey/token"
+)
+
+// ErrorCode identifies the kind of a parse error. Codes are stable, so that tools can match on
+// them instead of on messages.
+type ErrorCode int
+
+const (
+	// ErrUnexpectedToken reports a token other than the ones the grammar allows at that point,
+	// which are listed in ParseError.Expected.
+	ErrUnexpectedToken ErrorCode = iota + 1
+
+	// ErrMissingExpression reports a token that cannot start an expression where one is required.
+	ErrMissingExpression
+
+	// ErrIllegalToken reports an ILLEGAL token, which the lexer has described in its own errors.
+	ErrIllegalToken
+
+	// ErrInvalidNumber reports a number literal whose value cannot be represented.
+	ErrInvalidNumber
+
+	// ErrUnclosed reports a brace, parenthesis or bracket that is never closed. The error points
+	// at the opening delimiter, and Got holds the token found instead of the closing one.
+	ErrUnclosed
+
+	// ErrOutsideLoop reports a break or continue statement that is not inside a loop. A function
+	// literal starts afresh: a loop around it does not count.
+	ErrOutsideLoop
+
+	// ErrInvalidAssignment reports an assignment to something other than an identifier or an
+	// index expression, such as 1 = 2 or f() = 3. The error points at the assignment operator.
+	ErrInvalidAssignment
+)
+
+// String returns the name of the error code, such as "unexpected-token".
+func (c ErrorCode) String() string {
+	switch c {
+	case ErrUnexpectedToken:
+		return "unexpected-token"
+	case ErrMissingExpression:
+		return "missing-expression"
+	case ErrIllegalToken:
+		return "illegal-token"
+	case ErrInvalidNumber:
+		return "invalid-number"
+	case ErrUnclosed:
+		return "unclosed"
+	case ErrOutsideLoop:
+		return "outside-loop"
+	case ErrInvalidAssignment:
+		return "invalid-assignment"
+	}
+	return "unknown"
+}
+
+// ParseError describes a syntax error: its kind, the offending token and its source span.
+type ParseError struct {
+	Pos      token.Position    // start of the offending token
+	End      token.Position    // position just past the offending token
+	Code     ErrorCode         // kind of error
+	Expected []token.TokenType // token types that would have been accepted, if known
+	Got      token.Token       // the offending token
+	Msg      string
+}
+
+// Error formats the error as "position: message".
+func (e ParseError) Error() string {
+	return e.Pos.String() + ": " + e.Msg
+}
+
+// ParseErrors returns the syntax errors found so far, in the order they were encountered.
+func (p *Parser) ParseErrors() []ParseError {
+	return p.errors
+}
+
+// Errors returns the messages of the syntax errors found so far. It is a shorthand for reading
+// the Msg field of every error returned by ParseErrors.
+func (p *Parser) Errors() []string {
+	messages := make([]string, len(p.errors))
+	for i, err := range p.errors {
+		messages[i] = err.Msg
+	}
+	return messages
+}
+
+// addError records a syntax error about the given token. Only the first error of a statement is
+// recorded: the others are most likely consequences of the first one.
+func (p *Parser) addError(tok token.Token, code ErrorCode, msg string, expected ...token.TokenType) {
+	p.report(ParseError{
+		Pos:      tok.Pos,
+		End:      endOf(tok),
+		Code:     code,
+		Expected: expected,
+		Got:      tok,
+		Msg:      msg,
+	})
+}
+
+// addUnclosedError records that the delimiter open is not closed by the next token, as expected.
+func (p *Parser) addUnclosedError(open token.Token, closing token.TokenType) {
+	p.report(ParseError{
+		Pos:      open.Pos,
+		End:      endOf(open),
+		Code:     ErrUnclosed,
+		Expected: []token.TokenType{closing},
+		Got:      p.peek,
+		Msg:      fmt.Sprintf("unclosed %s: expected %s, got %s instead", open.Type, closing, p.peek.Type),
+	})
+}
+
+// report records err, unless an error has already been reported in the current statement.
+func (p *Parser) report(err ParseError) {
+	if p.panicking {
+		return
+	}
+	p.panicking = true
+	p.errors = append(p.errors, err)
+}
+
+// statementStarts lists the tokens that usually start a statement, where parsing resumes after an error.
+var statementStarts = map[token.TokenType]bool{
+	token.LET:      true,
+	token.RETURN:   true,
+	token.IF:       true,
+	token.WHILE:    true,
+	token.FOR:      true,
+	token.BREAK:    true,
+	token.CONTINUE: true,
+}
+
+// synchronize recovers from a syntax error by skipping the rest of the statement, which started with
+// depth delimiters open. It stops on the semicolon that ends the statement, or before the start of
+// the next statement or the brace that closes the enclosing block, so that the caller's next advance
+// lands where parsing can resume. Braces opened within the statement are skipped along with their
+// contents, while parentheses and brackets left open are abandoned. If the statement ran into the
+// closing brace of the enclosing block, the parser stops on it and marks itself as stalled, so that
+// the block sees its end.
+func (p *Parser) synchronize(depth int) {
+	p.panicking = false
+	for !p.tokenIs(p.current, token.EOF) {
+		if len(p.open) < depth {
+			if p.tokenIs(p.current, token.RBRACE) {
+				p.stalled = true
+				return
+			}
+			depth = len(p.open)
+		}
+
+		if !p.braceOpenAbove(depth) {
+			if p.tokenIs(p.current, token.SEMICOLON) || statementStarts[p.peek.Type] {
+				p.open = p.open[:depth]
+				return
+			}
+			if p.tokenIs(p.peek, token.EOF) || len(p.open) == depth && closesDelimiter(p.peek.Type) {
+				return
+			}
+		}
+		p.advanceToken()
+	}
+}
+
+// skipClauses skips the rest of the clauses of a for loop after a syntax error, up to the parenthesis
+// that closes them, which is the delimiter open at index depth, so that recovery does not stop on the
+// semicolons between the clauses. It gives up before a brace or the end of the input, as the
+// parenthesis is then most likely missing.
+func (p *Parser) skipClauses(depth int) {
+	for len(p.open) > depth && !p.tokenIs(p.peek, token.LBRACE) && !p.tokenIs(p.peek, token.RBRACE) && !p.tokenIs(p.peek, token.EOF) {
+		p.advanceToken()
+	}
+}
+
+// braceOpenAbove reports whether a brace is among the delimiters opened after the first depth ones.
+func (p *Parser) braceOpenAbove(depth int) bool {
+	for _, t := range p.open[depth:] {
+		if t == token.LBRACE {
+			return true
+		}
+	}
+	return false
+}
+
+// trackDelimiter updates the list of open delimiters with a token that has just been read. A closing
+// delimiter closes the innermost matching one, along with any left open within it; without a
+// matching one, it is ignored.
+func (p *Parser) trackDelimiter(t token.TokenType) {
+	if opensDelimiter(t) {
+		p.open = append(p.open, t)
+		return
+	}
+	if !closesDelimiter(t) {
+		return
+	}
+	for i := len(p.open) - 1; i >= 0; i-- {
+		if p.open[i] == opening(t) {
+			p.open = p.open[:i]
+			return
+		}
+	}
+}
+
+// depthBefore returns the number of delimiters open before the current token.
+func (p *Parser) depthBefore() int {
+	if opensDelimiter(p.current.Type) {
+		return len(p.open) - 1
+	}
+	return len(p.open)
+}
+
+// opening returns the opening delimiter matching a closing one.
+func opening(t token.TokenType) token.TokenType {
+	switch t {
+	case token.RPAREN:
+		return token.LPAREN
+	case token.RBRACKET:
+		return token.LBRACKET
+	}
+	return token.LBRACE
+}
+
+// opensDelimiter reports whether the token type is an opening brace, parenthesis or bracket.
+func opensDelimiter(t token.TokenType) bool {
+	return t == token.LBRACE || t == token.LPAREN || t == token.LBRACKET
+}
+
+// closesDelimiter reports whether the token type is a closing brace, parenthesis or bracket.
+func closesDelimiter(t token.TokenType) bool {
+	return t == token.RBRACE || t == token.RPAREN || t == token.RBRACKET
+}
+
+// endsConstruct reports whether a token of the given type ends the construct around the current
+// one, so that a delimiter still open at that point is missing its closing counterpart.
+func endsConstruct(t token.TokenType) bool {
+	return t == token.EOF || t == token.SEMICOLON || closesDelimiter(t)
+}
+
+// endOf returns the position just past the source text of the token.
+func endOf(tok token.Token) token.Position {
+	end := tok.Pos
+	if !end.IsValid() {
+		return end
+	}
+	end.Offset += len(tok.Raw)
+	for raw := tok.Raw; raw != ""; {
+		ch, width := utf8.DecodeRuneInString(raw)
+		if ch == '\n' {
+			end.Line++
+			end.Column = 1
+		} else {
+			end.Column++
+		}
+		raw = raw[width:]
+	}
+	return end
+}
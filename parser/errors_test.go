@@ -0,0 +1,292 @@
+package parser
+
+import (
+	"reflect"
+	"testing"
+
+	"monkey/lexer"
+	"monkey/token"
+)
+
+// TestParseErrors verifies that syntax errors carry a code, the offending token, its span and the
+// token types that were expected.
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected ParseError
+	}{
+		{
+			"let x 5;",
+			ParseError{
+				Pos:      token.Position{Offset: 6, Line: 1, Column: 7},
+				End:      token.Position{Offset: 7, Line: 1, Column: 8},
+				Code:     ErrUnexpectedToken,
+				Expected: []token.TokenType{token.ASSIGN},
+				Msg:      "expected next token to be =, got INT instead",
+			},
+		},
+		{
+			"x +\n  ;",
+			ParseError{
+				Pos:  token.Position{Offset: 6, Line: 2, Column: 3},
+				End:  token.Position{Offset: 7, Line: 2, Column: 4},
+				Code: ErrMissingExpression,
+				Msg:  "no prefix parse function for ; found",
+			},
+		},
+		{
+			"{\"a\" `multi\nline`}",
+			ParseError{
+				Pos:      token.Position{Offset: 5, Line: 1, Column: 6},
+				End:      token.Position{Offset: 17, Line: 2, Column: 6},
+				Code:     ErrUnexpectedToken,
+				Expected: []token.TokenType{token.COLON},
+				Msg:      `expected : after hash key "a", got STRING instead`,
+			},
+		},
+		{
+			"1 + @",
+			ParseError{
+				Pos:  token.Position{Offset: 4, Line: 1, Column: 5},
+				End:  token.Position{Offset: 5, Line: 1, Column: 6},
+				Code: ErrIllegalToken,
+				Msg:  `illegal token "@"`,
+			},
+		},
+		{
+			"99999999999999999999",
+			ParseError{
+				Pos:  token.Position{Offset: 0, Line: 1, Column: 1},
+				End:  token.Position{Offset: 20, Line: 1, Column: 21},
+				Code: ErrInvalidNumber,
+				Msg:  `could not parse "99999999999999999999" as integer`,
+			},
+		},
+		{
+			"while (x) { let f = fn() { break; }; }",
+			ParseError{
+				Pos:  token.Position{Offset: 27, Line: 1, Column: 28},
+				End:  token.Position{Offset: 32, Line: 1, Column: 33},
+				Code: ErrOutsideLoop,
+				Msg:  "break outside of a loop",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		p := New(lexer.New(test.input))
+		p.ParseProgram()
+
+		errors := p.ParseErrors()
+		if len(errors) == 0 {
+			t.Errorf("input %q - expected an error", test.input)
+			continue
+		}
+		got := errors[0]
+		if got.Pos != test.expected.Pos || got.End != test.expected.End {
+			t.Errorf("input %q - span wrong. expected=%s-%s, got=%s-%s", test.input,
+				test.expected.Pos, test.expected.End, got.Pos, got.End)
+		}
+		if got.Got.Pos != got.Pos {
+			t.Errorf("input %q - got token at %s, expected %s", test.input, got.Got.Pos, got.Pos)
+		}
+		if got.Code != test.expected.Code {
+			t.Errorf("input %q - code wrong. expected=%s, got=%s", test.input, test.expected.Code, got.Code)
+		}
+		if !reflect.DeepEqual(got.Expected, test.expected.Expected) {
+			t.Errorf("input %q - expected types wrong. expected=%v, got=%v", test.input, test.expected.Expected, got.Expected)
+		}
+		if got.Msg != test.expected.Msg {
+			t.Errorf("input %q - message wrong. expected=%q, got=%q", test.input, test.expected.Msg, got.Msg)
+		}
+	}
+}
+
+// TestParseErrors_Compatibility verifies that Errors lists the messages of the structured errors
+// and that errors format with their position.
+func TestParseErrors_Compatibility(t *testing.T) {
+	p := New(lexer.New("let = 1;\nlet y 2;", lexer.WithFilename("main.mk")))
+	p.ParseProgram()
+
+	expectedMessages := []string{
+		"expected next token to be IDENT, got = instead",
+		"expected next token to be =, got INT instead",
+	}
+	if !reflect.DeepEqual(p.Errors(), expectedMessages) {
+		t.Fatalf("Errors() wrong. expected=%q, got=%q", expectedMessages, p.Errors())
+	}
+
+	errors := p.ParseErrors()
+	if expected := "main.mk:2:7: expected next token to be =, got INT instead"; errors[1].Error() != expected {
+		t.Errorf("Error() wrong. expected=%q, got=%q", expected, errors[1].Error())
+	}
+	if errors[1].Code.String() != "unexpected-token" {
+		t.Errorf("Code.String() wrong. got=%q", errors[1].Code.String())
+	}
+}
+
+// TestErrorRecovery verifies that the parser reports every independent error once, resuming after
+// each one at the next statement, and keeps the statements that parse.
+func TestErrorRecovery(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedErrors []string
+		expectedString string
+	}{
+		{
+			"let x 5;\nlet = 10;\nlet y = 3;",
+			[]string{
+				"expected next token to be =, got INT instead",
+				"expected next token to be IDENT, got = instead",
+			},
+			"let y = 3;",
+		},
+		{
+			"let f = fn() { let x 1; let = 2; y };\nlet z = ;\nf",
+			[]string{
+				"expected next token to be =, got INT instead",
+				"expected next token to be IDENT, got = instead",
+				"no prefix parse function for ; found",
+			},
+			"let f = fn() { y };f",
+		},
+		{
+			"let a = (1 2 { b; c }); let d = 4",
+			[]string{"expected next token to be ), got INT instead"},
+			"let d = 4;",
+		},
+		{
+			"if (x) { let y = }\nz",
+			[]string{"no prefix parse function for } found"},
+			"if (x) {}z",
+		},
+		{
+			"if (x) { let h = {a: }; y }",
+			[]string{"no prefix parse function for } found"},
+			"if (x) { y }",
+		},
+		{
+			"if (x) { a + ) } let y = 1;",
+			[]string{"no prefix parse function for ) found"},
+			"if (x) {}let y = 1;",
+		},
+		{
+			"let x = 1; } let y = 2;",
+			[]string{"no prefix parse function for } found"},
+			"let x = 1;let y = 2;",
+		},
+		{
+			"let x = f(1, 2; let y = 3;",
+			[]string{"unclosed (: expected ), got ; instead"},
+			"let y = 3;",
+		},
+		{
+			"if (x) { f(1; y }\nf(@ ; g(2 }; let z = 3;",
+			[]string{
+				"unclosed (: expected ), got ; instead",
+				`illegal token "@"`,
+				"unclosed (: expected ), got } instead",
+			},
+			"if (x) { y }let z = 3;",
+		},
+		{
+			"while (x) { break; continue } break; let y = 1;",
+			[]string{"break outside of a loop"},
+			"while (x) { break; continue; }let y = 1;",
+		},
+		{
+			"for (let i = ; i < 3; i) { x } let y = 1;",
+			[]string{"no prefix parse function for ; found"},
+			"let y = 1;",
+		},
+		{
+			"if (@) { 1 }; let y = 1;",
+			[]string{`illegal token "@"`},
+			"let y = 1;",
+		},
+		{
+			"if (99999999999999999999) { 1 } else { 2 }\nlet y = 1;",
+			[]string{`could not parse "99999999999999999999" as integer`},
+			"let y = 1;",
+		},
+		{
+			"while (@) { x } let y = 1;",
+			[]string{`illegal token "@"`},
+			"let y = 1;",
+		},
+		{
+			"for (x in @) { 1 } let y = 1;",
+			[]string{`illegal token "@"`},
+			"let y = 1;",
+		},
+		{
+			"if (x) { y } else if (@) { z } let w = 1;",
+			[]string{`illegal token "@"`},
+			"let w = 1;",
+		},
+		{
+			"let f = fn() { let y = }; let c = 1;",
+			[]string{"no prefix parse function for } found"},
+			"let f = fn() {};let c = 1;",
+		},
+		{
+			"if (x) { b + }; let c = 1;",
+			[]string{"no prefix parse function for } found"},
+			"if (x) {}let c = 1;",
+		},
+		{
+			"if (x) { return 1 + }; let c = 1;",
+			[]string{"no prefix parse function for } found"},
+			"if (x) {}let c = 1;",
+		},
+		{
+			"if (x) { let y = 1;",
+			[]string{"unclosed {: expected }, got EOF instead"},
+			"",
+		},
+		{
+			"fn() { if (x) { y",
+			[]string{"unclosed {: expected }, got EOF instead", "unclosed {: expected }, got EOF instead"},
+			"",
+		},
+		{
+			"let a = [1, 2 }; let b = (3 + 4]; let c = 5;",
+			[]string{"unclosed [: expected ], got } instead", "unclosed (: expected ), got ] instead"},
+			"let c = 5;",
+		},
+	}
+
+	for _, test := range tests {
+		p := New(lexer.New(test.input))
+		program := p.ParseProgram()
+
+		if !reflect.DeepEqual(p.Errors(), test.expectedErrors) {
+			t.Errorf("input %q - errors wrong.\nexpected=%q\ngot=     %q", test.input, test.expectedErrors, p.Errors())
+		}
+		if program.String() != test.expectedString {
+			t.Errorf("input %q - program wrong. expected=%q, got=%q", test.input, test.expectedString, program.String())
+		}
+	}
+}
+
+// TestErrorRecovery_UnclosedPositions verifies that unclosed delimiters are reported against the
+// opening delimiter.
+func TestErrorRecovery_UnclosedPositions(t *testing.T) {
+	p := New(lexer.New("fn(x) {\n  if (x) {\n    foo(x, [1, 2\n"))
+	p.ParseProgram()
+
+	expected := []token.Position{
+		{Offset: 30, Line: 3, Column: 12}, // [
+		{Offset: 17, Line: 2, Column: 10}, // { of if
+		{Offset: 6, Line: 1, Column: 7},   // { of fn
+	}
+	errors := p.ParseErrors()
+	if len(errors) != len(expected) {
+		t.Fatalf("expected %d errors, got %d: %q", len(expected), len(errors), p.Errors())
+	}
+	for i, pos := range expected {
+		if errors[i].Pos != pos || errors[i].Code != ErrUnclosed {
+			t.Errorf("errors[%d] wrong. expected unclosed at %s, got %s at %s", i, pos, errors[i].Code, errors[i].Pos)
+		}
+	}
+}
@@ -5,9 +5,9 @@ package parser
 import (
 	"fmt"
 	"monkey/ast"
-	"monkey/lexer"
 	"monkey/token"
 	"strconv"
+	"strings"
 )
 
 // Precedence levels are used to dictate the order in which operators are parsed.
@@ -15,27 +15,72 @@ import (
 // and division are executed before addition and subtraction, for instance.
 // The iota keyword in Go auto-increments, providing an easy way to assign increasing
 // values to each item in the constant list.
+//
+// As in Go, the bitwise operators share the levels of their arithmetic counterparts,
+// so that a & b == c groups as (a & b) == c.
 const (
 	_ int = iota
 	LOWEST
+	ASSIGNMENT  // = or += and the other compound assignments
+	LOGICAL_OR  // ||
+	LOGICAL_AND // &&
 	EQUALS      // ==
 	LESSGREATER // > or <
-	SUM         // +
-	PRODUCT     // *
+	SUM         // + or | or ^
+	PRODUCT     // * or % or & or <<
 	PREFIX      // -X or !X
+	POWER       // X ** Y, binding tighter than a prefix operator so that -2 ** 2 is -(2 ** 2)
 	CALL        // myFunction(X)
+	INDEX       // array[index]
 )
 
 var precedences = map[token.TokenType]int{
-	token.EQ:       EQUALS,
-	token.NOT_EQ:   EQUALS,
-	token.LT:       LESSGREATER,
-	token.GT:       LESSGREATER,
-	token.PLUS:     SUM,
-	token.MINUS:    SUM,
-	token.SLASH:    PRODUCT,
-	token.ASTERISK: PRODUCT,
-	token.LPAREN:   CALL,
+	token.ASSIGN:          ASSIGNMENT,
+	token.PLUS_ASSIGN:     ASSIGNMENT,
+	token.MINUS_ASSIGN:    ASSIGNMENT,
+	token.ASTERISK_ASSIGN: ASSIGNMENT,
+	token.SLASH_ASSIGN:    ASSIGNMENT,
+	token.PERCENT_ASSIGN:  ASSIGNMENT,
+	token.OR:              LOGICAL_OR,
+	token.AND:             LOGICAL_AND,
+	token.EQ:              EQUALS,
+	token.NOT_EQ:          EQUALS,
+	token.LT:              LESSGREATER,
+	token.GT:              LESSGREATER,
+	token.LT_EQ:           LESSGREATER,
+	token.GT_EQ:           LESSGREATER,
+	token.PLUS:            SUM,
+	token.MINUS:           SUM,
+	token.PIPE:            SUM,
+	token.CARET:           SUM,
+	token.SLASH:           PRODUCT,
+	token.ASTERISK:        PRODUCT,
+	token.PERCENT:         PRODUCT,
+	token.AMPERSAND:       PRODUCT,
+	token.SHIFT_LEFT:      PRODUCT,
+	token.SHIFT_RIGHT:     PRODUCT,
+	token.POWER:           POWER,
+	token.LPAREN:          CALL,
+	token.LBRACKET:        INDEX,
+}
+
+// rightAssociative lists the infix operators that group from the right, so that
+// a ** b ** c is parsed as a ** (b ** c), and a = b = c as a = (b = c).
+var rightAssociative = map[token.TokenType]bool{
+	token.POWER:           true,
+	token.ASSIGN:          true,
+	token.PLUS_ASSIGN:     true,
+	token.MINUS_ASSIGN:    true,
+	token.ASTERISK_ASSIGN: true,
+	token.SLASH_ASSIGN:    true,
+	token.PERCENT_ASSIGN:  true,
+}
+
+// shortCircuit lists the infix operators whose right operand is only evaluated
+// when the left operand does not already decide the result.
+var shortCircuit = map[token.TokenType]bool{
+	token.AND: true,
+	token.OR:  true,
 }
 
 type (
@@ -45,53 +90,120 @@ type (
 	infixParseFn func(ast.Expression) ast.Expression
 )
 
+// TokenSource supplies the tokens a Parser consumes, such as a *lexer.Lexer, or a *lexer.Stream
+// to parse while the input is still being lexed on another goroutine.
+type TokenSource interface {
+	NextToken() token.Token
+}
+
 // Parser represents the Monkey language parser structure.
 type Parser struct {
-	lexer          *lexer.Lexer
+	lexer          TokenSource
 	current        token.Token
 	peek           token.Token
-	errors         []string
+	errors         []ParseError
 	prefixParseFns map[token.TokenType]prefixParseFn
 	infixParseFns  map[token.TokenType]infixParseFn
+	precedences    map[token.TokenType]int
+
+	// Error recovery state, see synchronize.
+	open      []token.TokenType // delimiters opened and not closed yet, up to the current token
+	panicking bool              // an error was reported in the current statement, further ones are dropped
+	stalled   bool              // recovery stopped on the closing brace of the enclosing block
+
+	loopDepth int // number of loops around the current token, within the innermost function literal
 }
 
-// New initializes a new Parser instance.
-func New(l *lexer.Lexer) *Parser {
+// Option configures optional behaviour of a Parser.
+type Option func(*Parser)
+
+// WithDialect makes the parser accept the operators of the given dialect, which must also be passed
+// to the lexer: operators with a precedence are parsed as infix expressions, and operators marked
+// as prefix as prefix expressions. Dialect keywords that alias Monkey's own token types, such as
+// "when" for token.IF, are parsed like the keyword they stand for. Keywords with token types of
+// their own need a parse function, given with WithPrefix.
+func WithDialect(d token.Dialect) Option {
+	return func(p *Parser) {
+		p.precedences = make(map[token.TokenType]int, len(precedences)+len(d.Operators))
+		for tokenType, precedence := range precedences {
+			p.precedences[tokenType] = precedence
+		}
+
+		for _, op := range d.Operators {
+			if op.Precedence > 0 {
+				p.precedences[op.Type] = op.Precedence
+				p.registerInfix(op.Type, p.parseInfixExpression)
+			}
+			if op.Prefix {
+				p.registerPrefix(op.Type, p.parsePrefixExpression)
+			}
+		}
+	}
+}
+
+// New initializes a new Parser instance reading tokens from l.
+func New(l TokenSource, opts ...Option) *Parser {
 	p := &Parser{
 		lexer:          l,
 		prefixParseFns: make(map[token.TokenType]prefixParseFn),
 		infixParseFns:  make(map[token.TokenType]infixParseFn),
+		precedences:    precedences,
 	}
 
 	// Set up initial tokens for curToken and peekToken.
 	p.advanceToken()
 	p.advanceToken()
 
+	p.registerPrefix(token.ILLEGAL, p.parseIllegal)
 	p.registerPrefix(token.IDENT, p.parseIdentifier)
 	p.registerPrefix(token.INT, p.parseIntegerLiteral)
+	p.registerPrefix(token.FLOAT, p.parseFloatLiteral)
+	p.registerPrefix(token.STRING, p.parseStringLiteral)
+	p.registerPrefix(token.STRING_START, p.parseInterpolatedString)
 	p.registerPrefix(token.BANG, p.parsePrefixExpression)
 	p.registerPrefix(token.MINUS, p.parsePrefixExpression)
 	p.registerPrefix(token.TRUE, p.parseBoolean)
 	p.registerPrefix(token.FALSE, p.parseBoolean)
 	p.registerPrefix(token.LPAREN, p.parseGroupedExpression)
 	p.registerPrefix(token.IF, p.parseIfExpression)
+	p.registerPrefix(token.FUNCTION, p.parseFunctionLiteral)
+	p.registerPrefix(token.LBRACKET, p.parseArrayLiteral)
+	p.registerPrefix(token.LBRACE, p.parseHashLiteral)
 
 	p.registerInfix(token.PLUS, p.parseInfixExpression)
 	p.registerInfix(token.MINUS, p.parseInfixExpression)
 	p.registerInfix(token.ASTERISK, p.parseInfixExpression)
 	p.registerInfix(token.SLASH, p.parseInfixExpression)
+	p.registerInfix(token.PERCENT, p.parseInfixExpression)
+	p.registerInfix(token.POWER, p.parseInfixExpression)
 	p.registerInfix(token.GT, p.parseInfixExpression)
 	p.registerInfix(token.LT, p.parseInfixExpression)
+	p.registerInfix(token.GT_EQ, p.parseInfixExpression)
+	p.registerInfix(token.LT_EQ, p.parseInfixExpression)
 	p.registerInfix(token.EQ, p.parseInfixExpression)
 	p.registerInfix(token.NOT_EQ, p.parseInfixExpression)
+	p.registerInfix(token.AND, p.parseInfixExpression)
+	p.registerInfix(token.OR, p.parseInfixExpression)
+	p.registerInfix(token.AMPERSAND, p.parseInfixExpression)
+	p.registerInfix(token.PIPE, p.parseInfixExpression)
+	p.registerInfix(token.CARET, p.parseInfixExpression)
+	p.registerInfix(token.SHIFT_LEFT, p.parseInfixExpression)
+	p.registerInfix(token.SHIFT_RIGHT, p.parseInfixExpression)
+	p.registerInfix(token.ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.PLUS_ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.MINUS_ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.ASTERISK_ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.SLASH_ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.PERCENT_ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.LPAREN, p.parseCallExpression)
+	p.registerInfix(token.LBRACKET, p.parseIndexExpression)
+
+	for _, opt := range opts {
+		opt(p)
+	}
 	return p
 }
 
-// Errors returns a slice of error messages encountered during parsing.
-func (p *Parser) Errors() []string {
-	return p.errors
-}
-
 // registerPrefix registers a prefix parsing function for a given token type.
 func (p *Parser) registerPrefix(tokenType token.TokenType, fn prefixParseFn) {
 	p.prefixParseFns[tokenType] = fn
@@ -113,21 +225,42 @@ func (parser *Parser) ParseProgram() *ast.Program {
 		if statement != nil {
 			program.Statements = append(program.Statements, statement)
 		}
+		// At the top level, a closing brace that stopped recovery closes nothing: it is skipped.
+		parser.stalled = false
 		parser.advanceToken()
 	}
 	return program
 }
 
 // parseStatement dispatches the correct parsing function based on the current token type.
+// A statement containing a syntax error is dropped, and the parser skips to a point where
+// the next statement can be parsed.
 func (p *Parser) parseStatement() ast.Statement {
+	depth := p.depthBefore()
+
+	var statement ast.Statement
 	switch p.current.Type {
 	case token.LET:
-		return p.parseLetStatement()
+		statement = p.parseLetStatement()
 	case token.RETURN:
-		return p.parseReturnStatement()
+		statement = p.parseReturnStatement()
+	case token.WHILE:
+		statement = p.parseWhileStatement()
+	case token.FOR:
+		statement = p.parseForStatement()
+	case token.BREAK:
+		statement = p.parseBreakStatement()
+	case token.CONTINUE:
+		statement = p.parseContinueStatement()
 	default:
-		return p.parseExpressionStatement()
+		statement = p.parseExpressionStatement()
 	}
+
+	if p.panicking {
+		p.synchronize(depth)
+		return nil
+	}
+	return statement
 }
 
 func (p *Parser) parseLetStatement() ast.Statement {
@@ -143,23 +276,165 @@ func (p *Parser) parseLetStatement() ast.Statement {
 		return nil
 	}
 
-	// TODO: Skip until we encounter a semicolon for simplicity now. We'll handle expressions later.
-	p.skipToStatementEnd()
+	p.advanceToken()
+	statement.Value = p.parseExpression(LOWEST)
+	p.skipOptionalSemicolon()
 	return statement
 }
 func (p *Parser) parseReturnStatement() ast.Statement {
 	statement := &ast.ReturnStatement{Token: p.current}
-	p.skipToStatementEnd()
+
+	// A bare return ends with its statement, or with the enclosing block or program.
+	if !p.tokenIs(p.peek, token.SEMICOLON) && !p.tokenIs(p.peek, token.RBRACE) && !p.tokenIs(p.peek, token.EOF) {
+		p.advanceToken()
+		statement.Value = p.parseExpression(LOWEST)
+	}
+	p.skipOptionalSemicolon()
+	return statement
+}
+
+func (p *Parser) parseWhileStatement() ast.Statement {
+	statement := &ast.WhileStatement{Token: p.current}
+	if !p.advanceIfPeekIs(token.LPAREN) {
+		return nil
+	}
+	open := p.current
+	p.advanceToken()
+	statement.Condition = p.parseExpression(LOWEST)
+	if !p.advanceIfClosed(open, token.RPAREN) {
+		return nil
+	}
+	if !p.advanceIfPeekIs(token.LBRACE) {
+		return nil
+	}
+	statement.Body = p.parseLoopBody()
+	return statement
+}
+
+// parseForStatement parses both forms of for loops: for (x in xs) { ... } and the C-style
+// for (init; condition; update) { ... }, whose clauses may be left empty.
+func (p *Parser) parseForStatement() ast.Statement {
+	forToken := p.current
+	if !p.advanceIfPeekIs(token.LPAREN) {
+		return nil
+	}
+	open, depth := p.current, p.depthBefore()
+	p.advanceToken()
+
+	if p.tokenIs(p.current, token.IDENT) && p.tokenIs(p.peek, token.IN) {
+		statement := &ast.ForInStatement{Token: forToken}
+		statement.Variable = &ast.Identifier{Token: p.current, Value: p.current.Literal}
+		p.advanceToken()
+		p.advanceToken()
+		statement.Iterable = p.parseExpression(LOWEST)
+		if !p.advanceIfClosed(open, token.RPAREN) {
+			return nil
+		}
+		if !p.advanceIfPeekIs(token.LBRACE) {
+			return nil
+		}
+		statement.Body = p.parseLoopBody()
+		return statement
+	}
+
+	statement := &ast.ForStatement{Token: forToken}
+	if !p.parseForClauses(statement, open) {
+		p.skipClauses(depth)
+		return nil
+	}
+	if !p.advanceIfPeekIs(token.LBRACE) {
+		return nil
+	}
+	statement.Body = p.parseLoopBody()
+	return statement
+}
+
+// parseForClauses parses the clauses of a C-style for loop, from the first token after the open
+// parenthesis up to the closing one. It reports false on a syntax error.
+func (p *Parser) parseForClauses(statement *ast.ForStatement, open token.Token) bool {
+	if !p.tokenIs(p.current, token.SEMICOLON) {
+		if p.tokenIs(p.current, token.LET) {
+			statement.Init = p.parseLetStatement()
+		} else {
+			statement.Init = p.parseExpressionStatement()
+		}
+		// A let statement is returned even when its value is missing.
+		if statement.Init == nil || p.panicking {
+			return false
+		}
+		// Both statements end on their semicolon when there is one.
+		if !p.tokenIs(p.current, token.SEMICOLON) && !p.advanceIfPeekIs(token.SEMICOLON) {
+			return false
+		}
+	}
+
+	if !p.tokenIs(p.peek, token.SEMICOLON) {
+		p.advanceToken()
+		statement.Condition = p.parseExpression(LOWEST)
+	}
+	if !p.advanceIfPeekIs(token.SEMICOLON) {
+		return false
+	}
+
+	if !p.tokenIs(p.peek, token.RPAREN) {
+		p.advanceToken()
+		statement.Update = p.parseExpression(LOWEST)
+	}
+	return p.advanceIfClosed(open, token.RPAREN) && !p.panicking
+}
+
+// parseLoopBody parses the block of a loop, in which break and continue are allowed.
+func (p *Parser) parseLoopBody() *ast.BlockStatement {
+	p.loopDepth++
+	defer func() { p.loopDepth-- }()
+	return p.parseBlockStatement()
+}
+
+func (p *Parser) parseBreakStatement() ast.Statement {
+	statement := &ast.BreakStatement{Token: p.current}
+	if p.loopDepth == 0 {
+		p.addError(p.current, ErrOutsideLoop, "break outside of a loop")
+		return nil
+	}
+	if p.tokenIs(p.peek, token.SEMICOLON) {
+		p.advanceToken()
+	}
+	return statement
+}
+
+func (p *Parser) parseContinueStatement() ast.Statement {
+	statement := &ast.ContinueStatement{Token: p.current}
+	if p.loopDepth == 0 {
+		p.addError(p.current, ErrOutsideLoop, "continue outside of a loop")
+		return nil
+	}
+	if p.tokenIs(p.peek, token.SEMICOLON) {
+		p.advanceToken()
+	}
 	return statement
 }
 
 func (p *Parser) parseExpressionStatement() ast.Statement {
 	statement := &ast.ExpressionStatement{Token: p.current}
 	statement.Expression = p.parseExpression(LOWEST)
-	if p.tokenIs(p.peek, token.SEMICOLON) {
+	p.skipOptionalSemicolon()
+	return statement
+}
+
+// skipOptionalSemicolon advances onto the semicolon that may end a statement. After a syntax error
+// it is left to synchronize, as the statement may have stopped on the closing brace of its block,
+// and the semicolon then follows the block rather than the statement.
+func (p *Parser) skipOptionalSemicolon() {
+	if p.tokenIs(p.peek, token.SEMICOLON) && !p.panicking {
 		p.advanceToken()
 	}
-	return statement
+}
+
+// parseIllegal reports a token the lexer could not make sense of. The lexer's own errors describe
+// what is wrong with it in more detail.
+func (p *Parser) parseIllegal() ast.Expression {
+	p.addError(p.current, ErrIllegalToken, fmt.Sprintf("illegal token %q", p.current.Literal))
+	return nil
 }
 
 func (p *Parser) parseIdentifier() ast.Expression {
@@ -168,10 +443,17 @@ func (p *Parser) parseIdentifier() ast.Expression {
 
 func (p *Parser) parseIntegerLiteral() ast.Expression {
 	integerLiteral := &ast.IntegerLiteral{Token: p.current}
-	value, err := strconv.ParseInt(p.current.Literal, 0, 64)
+
+	// Without a base prefix a literal is decimal, leading zeros included: strconv would take 0123
+	// for the octal 83, and reject 09.
+	literal, base := p.current.Literal, 0
+	if len(literal) < 2 || literal[0] != '0' || !strings.ContainsRune("xXoObB", rune(literal[1])) {
+		literal, base = strings.ReplaceAll(literal, "_", ""), 10
+	}
+	value, err := strconv.ParseInt(literal, base, 64)
 
 	if err != nil {
-		p.addError(fmt.Sprintf("could not parse %q as integer", p.current.Literal))
+		p.addError(p.current, ErrInvalidNumber, fmt.Sprintf("could not parse %q as integer", p.current.Literal))
 		return nil
 	}
 
@@ -179,10 +461,50 @@ func (p *Parser) parseIntegerLiteral() ast.Expression {
 	return integerLiteral
 }
 
+func (p *Parser) parseFloatLiteral() ast.Expression {
+	floatLiteral := &ast.FloatLiteral{Token: p.current}
+	value, err := strconv.ParseFloat(p.current.Literal, 64)
+
+	if err != nil {
+		p.addError(p.current, ErrInvalidNumber, fmt.Sprintf("could not parse %q as float", p.current.Literal))
+		return nil
+	}
+
+	floatLiteral.Value = value
+	return floatLiteral
+}
+
+func (p *Parser) parseStringLiteral() ast.Expression {
+	return &ast.StringLiteral{Token: p.current, Value: p.current.Literal}
+}
+
+// parseInterpolatedString parses a string with embedded expressions, from its STRING_START token
+// up to its STRING_END token.
+func (p *Parser) parseInterpolatedString() ast.Expression {
+	str := &ast.InterpolatedString{Token: p.current}
+	for {
+		if p.current.Literal != "" {
+			str.Parts = append(str.Parts, &ast.StringLiteral{Token: p.current, Value: p.current.Literal})
+		}
+		if p.tokenIs(p.current, token.STRING_END) {
+			return str
+		}
+
+		p.advanceToken()
+		str.Parts = append(str.Parts, p.parseExpression(LOWEST))
+
+		if p.tokenIs(p.peek, token.STRING_MIDDLE) {
+			p.advanceToken()
+		} else if !p.advanceIfPeekIs(token.STRING_END) {
+			return nil
+		}
+	}
+}
+
 func (p *Parser) parseExpression(precedence int) ast.Expression {
 	prefix := p.prefixParseFns[p.current.Type]
 	if prefix == nil {
-		p.noPrefixParseFnError(p.current.Type)
+		p.noPrefixParseFnError(p.current)
 		return nil
 	}
 	leftExp := prefix()
@@ -212,20 +534,56 @@ func (p *Parser) parsePrefixExpression() ast.Expression {
 
 func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
 	expression := &ast.InfixExpression{
+		Token:        p.current,
+		Operator:     p.current.Literal,
+		Left:         left,
+		ShortCircuit: shortCircuit[p.current.Type],
+	}
+
+	precedence := p.currentPrecedence()
+	if rightAssociative[p.current.Type] {
+		// Parsing the right operand one level lower lets it absorb further uses of the same operator.
+		precedence--
+	}
+	p.advanceToken()
+
+	expression.Right = p.parseExpression(precedence)
+	return expression
+}
+
+// parseAssignExpression parses an assignment to target, which must be an identifier or an index
+// expression: other expressions do not designate a place a value can be stored in.
+func (p *Parser) parseAssignExpression(target ast.Expression) ast.Expression {
+	expression := &ast.AssignExpression{
 		Token:    p.current,
+		Target:   target,
 		Operator: p.current.Literal,
-		Left:     left,
+	}
+
+	if p.panicking {
+		// The target, or part of it, failed to parse: that has already been reported, and the
+		// target may have missing nodes that cannot be printed.
+		return nil
+	}
+	switch target.(type) {
+	case *ast.Identifier, *ast.IndexExpression:
+	default:
+		p.addError(p.current, ErrInvalidAssignment, fmt.Sprintf("cannot assign to %s", target.String()))
+		return nil
 	}
 
 	precedence := p.currentPrecedence()
+	if rightAssociative[p.current.Type] {
+		precedence--
+	}
 	p.advanceToken()
 
-	expression.Right = p.parseExpression(precedence)
+	expression.Value = p.parseExpression(precedence)
 	return expression
 }
 
-func (p *Parser) noPrefixParseFnError(t token.TokenType) {
-	p.addError(fmt.Sprintf("no prefix parse function for %s found", t))
+func (p *Parser) noPrefixParseFnError(tok token.Token) {
+	p.addError(tok, ErrMissingExpression, fmt.Sprintf("no prefix parse function for %s found", tok.Type))
 }
 
 func (p *Parser) parseBoolean() ast.Expression {
@@ -233,9 +591,10 @@ func (p *Parser) parseBoolean() ast.Expression {
 }
 
 func (p *Parser) parseGroupedExpression() ast.Expression {
+	open := p.current
 	p.advanceToken()
 	expression := p.parseExpression(LOWEST)
-	if !p.advanceIfPeekIs(token.RPAREN) {
+	if !p.advanceIfClosed(open, token.RPAREN) {
 		return nil
 	}
 	return expression
@@ -246,9 +605,10 @@ func (p *Parser) parseIfExpression() ast.Expression {
 	if !p.advanceIfPeekIs(token.LPAREN) {
 		return nil
 	}
+	open := p.current
 	p.advanceToken()
 	expression.Condition = p.parseExpression(LOWEST)
-	if !p.advanceIfPeekIs(token.RPAREN) {
+	if !p.advanceIfClosed(open, token.RPAREN) {
 		return nil
 	}
 	if !p.advanceIfPeekIs(token.LBRACE) {
@@ -259,6 +619,19 @@ func (p *Parser) parseIfExpression() ast.Expression {
 	if p.tokenIs(p.peek, token.ELSE) {
 		p.advanceToken()
 
+		if p.tokenIs(p.peek, token.IF) {
+			// else if: the rest of the chain is the only statement of the alternative.
+			p.advanceToken()
+			alternative := &ast.BlockStatement{Token: p.current}
+			elseIf := p.parseIfExpression()
+			if elseIf == nil {
+				return nil
+			}
+			alternative.Statements = []ast.Statement{&ast.ExpressionStatement{Token: alternative.Token, Expression: elseIf}}
+			expression.Alternative = alternative
+			return expression
+		}
+
 		if !p.advanceIfPeekIs(token.LBRACE) {
 			return nil
 		}
@@ -268,37 +641,216 @@ func (p *Parser) parseIfExpression() ast.Expression {
 	return expression
 }
 
+func (p *Parser) parseFunctionLiteral() ast.Expression {
+	literal := &ast.FunctionLiteral{Token: p.current}
+	if !p.advanceIfPeekIs(token.LPAREN) {
+		return nil
+	}
+
+	literal.Parameters = p.parseFunctionParameters()
+	if literal.Parameters == nil {
+		return nil
+	}
+	if !p.advanceIfPeekIs(token.LBRACE) {
+		return nil
+	}
+
+	// Loops around the function do not extend into its body.
+	loopDepth := p.loopDepth
+	p.loopDepth = 0
+	literal.Body = p.parseBlockStatement()
+	p.loopDepth = loopDepth
+	return literal
+}
+
+// parseFunctionParameters parses the comma-separated parameter names of a function literal,
+// from the opening parenthesis to the closing one. It returns nil on error.
+func (p *Parser) parseFunctionParameters() []*ast.Identifier {
+	open := p.current
+	identifiers := []*ast.Identifier{}
+	if p.tokenIs(p.peek, token.RPAREN) {
+		p.advanceToken()
+		return identifiers
+	}
+
+	for {
+		if !p.advanceIfPeekIs(token.IDENT) {
+			return nil
+		}
+		identifiers = append(identifiers, &ast.Identifier{Token: p.current, Value: p.current.Literal})
+		if !p.tokenIs(p.peek, token.COMMA) {
+			break
+		}
+		p.advanceToken()
+	}
+
+	if !p.advanceIfClosed(open, token.RPAREN) {
+		return nil
+	}
+	return identifiers
+}
+
+func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
+	expression := &ast.CallExpression{Token: p.current, Function: function}
+	expression.Arguments = p.parseExpressionList(token.RPAREN)
+	if expression.Arguments == nil {
+		return nil
+	}
+	return expression
+}
+
+func (p *Parser) parseArrayLiteral() ast.Expression {
+	array := &ast.ArrayLiteral{Token: p.current}
+	array.Elements = p.parseExpressionList(token.RBRACKET)
+	if array.Elements == nil {
+		return nil
+	}
+	return array
+}
+
+// parseHashLiteral parses a hash literal. Blocks are only expected after if, else and fn, which
+// parse them directly, so a brace in expression position always opens a hash literal.
+func (p *Parser) parseHashLiteral() ast.Expression {
+	open := p.current
+	hash := &ast.HashLiteral{Token: p.current, Pairs: []ast.HashPair{}}
+	if p.tokenIs(p.peek, token.RBRACE) {
+		p.advanceToken()
+		return hash
+	}
+
+	for {
+		p.advanceToken()
+		key := p.parseExpression(LOWEST)
+		if key == nil {
+			return nil
+		}
+		if !p.tokenIs(p.peek, token.COLON) {
+			p.addError(p.peek, ErrUnexpectedToken,
+				fmt.Sprintf("expected : after hash key %s, got %s instead", key, p.peek.Type), token.COLON)
+			return nil
+		}
+		p.advanceToken()
+
+		p.advanceToken()
+		value := p.parseExpression(LOWEST)
+		hash.Pairs = append(hash.Pairs, ast.HashPair{Key: key, Value: value})
+
+		switch {
+		case p.tokenIs(p.peek, token.RBRACE):
+			p.advanceToken()
+			return hash
+		case p.tokenIs(p.peek, token.COMMA):
+			p.advanceToken()
+		case endsConstruct(p.peek.Type):
+			p.addUnclosedError(open, token.RBRACE)
+			return nil
+		default:
+			p.addError(p.peek, ErrUnexpectedToken,
+				fmt.Sprintf("expected , or } after the value of hash key %s, got %s instead", key, p.peek.Type),
+				token.COMMA, token.RBRACE)
+			return nil
+		}
+	}
+}
+
+func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
+	expression := &ast.IndexExpression{Token: p.current, Left: left}
+	p.advanceToken()
+	expression.Index = p.parseExpression(LOWEST)
+	if !p.advanceIfClosed(expression.Token, token.RBRACKET) {
+		return nil
+	}
+	return expression
+}
+
+// parseExpressionList parses comma-separated expressions, such as the arguments of a call or the
+// elements of an array, from the opening delimiter up to the given closing one. It returns nil on error.
+func (p *Parser) parseExpressionList(end token.TokenType) []ast.Expression {
+	open := p.current
+	list := []ast.Expression{}
+	if p.tokenIs(p.peek, end) {
+		p.advanceToken()
+		return list
+	}
+
+	p.advanceToken()
+	list = append(list, p.parseExpression(LOWEST))
+	for p.tokenIs(p.peek, token.COMMA) {
+		p.advanceToken()
+		p.advanceToken()
+		list = append(list, p.parseExpression(LOWEST))
+	}
+
+	if !p.advanceIfClosed(open, end) {
+		return nil
+	}
+	return list
+}
+
+// parseBlockStatement parses the statements of a block up to its closing brace, which is reported
+// as missing against the opening brace if the input ends first.
 func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 	block := &ast.BlockStatement{Token: p.current}
 	block.Statements = []ast.Statement{}
+	if p.panicking {
+		// The enclosing statement already has an error and is dropped: its first nested statement
+		// must not take over the error, so the block is left for synchronize to skip.
+		return block
+	}
 	p.advanceToken()
 	for !p.tokenIs(p.current, token.RBRACE) && !p.tokenIs(p.current, token.EOF) {
 		statement := p.parseStatement()
 		if statement != nil {
 			block.Statements = append(block.Statements, statement)
 		}
+		if p.stalled {
+			// Recovery stopped on the closing brace of this block.
+			p.stalled = false
+			continue
+		}
 		p.advanceToken()
 	}
+
+	if p.tokenIs(p.current, token.EOF) {
+		p.addUnclosedError(block.Token, token.RBRACE)
+	}
 	return block
 }
 
 // Token navigation and validation functions.
 
-// advanceToken advances to the next token.
+// advanceToken advances to the next token, skipping comments, and keeps track of the nesting
+// of delimiters.
 func (p *Parser) advanceToken() {
 	p.current = p.peek
 	p.peek = p.lexer.NextToken()
+	for p.tokenIs(p.peek, token.COMMENT) {
+		p.peek = p.lexer.NextToken()
+	}
+
+	p.trackDelimiter(p.current.Type)
 }
 
 // advanceIfPeekIs advances to the next token if the peek token matches the given type.
-// If not, it logs an error and skips to the end of the statement.
+// If not, it logs an error, leaving the recovery to the enclosing statement.
 func (parser *Parser) advanceIfPeekIs(t token.TokenType) bool {
 	if parser.tokenIs(parser.peek, t) {
 		parser.advanceToken()
 		return true
 	}
-	parser.addError(fmt.Sprintf("expected next token to be %s, got %s instead", t, parser.peek.Type))
-	parser.skipToStatementEnd()
+	parser.addError(parser.peek, ErrUnexpectedToken,
+		fmt.Sprintf("expected next token to be %s, got %s instead", t, parser.peek.Type), t)
+	return false
+}
+
+// advanceIfClosed advances to the next token if it closes the delimiter open. If the enclosing
+// construct or the input ends first, the delimiter is reported as unclosed; otherwise the next
+// token is reported as unexpected.
+func (p *Parser) advanceIfClosed(open token.Token, closing token.TokenType) bool {
+	if p.tokenIs(p.peek, closing) || !endsConstruct(p.peek.Type) {
+		return p.advanceIfPeekIs(closing)
+	}
+	p.addUnclosedError(open, closing)
 	return false
 }
 
@@ -309,7 +861,7 @@ func (p *Parser) tokenIs(token token.Token, tokenType token.TokenType) bool {
 
 // currentPrecedence returns the precedence of the current token.
 func (p *Parser) currentPrecedence() int {
-	if precedence, ok := precedences[p.current.Type]; ok {
+	if precedence, ok := p.precedences[p.current.Type]; ok {
 		return precedence
 	}
 	return LOWEST
@@ -317,21 +869,8 @@ func (p *Parser) currentPrecedence() int {
 
 // peekPrecedence returns the precedence of the next token.
 func (p *Parser) peekPrecedence() int {
-	if prec, ok := precedences[p.peek.Type]; ok {
+	if prec, ok := p.precedences[p.peek.Type]; ok {
 		return prec
 	}
 	return LOWEST
 }
-
-// skipToStatementEnd skips tokens until a semicolon or EOF is encountered.
-// This is useful for error recovery.
-func (p *Parser) skipToStatementEnd() {
-	for p.current.Type != token.SEMICOLON && p.current.Type != token.EOF {
-		p.advanceToken()
-	}
-}
-
-// addError logs a parsing error.
-func (p *Parser) addError(msg string) {
-	p.errors = append(p.errors, msg)
-}
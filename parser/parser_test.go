@@ -1,10 +1,12 @@
 package parser
 
 import (
+	"context"
 	"fmt"
 	"monkey/ast"
 	"monkey/lexer"
 	"monkey/token"
+	"strings"
 	"testing"
 )
 
@@ -45,9 +47,42 @@ let foobar = 838383;
 	assertNumberOfStatements(t, program, 3)
 
 	expectedIdentifiers := []string{"x", "y", "foobar"}
+	expectedValues := []int64{5, 10, 838383}
 	for i, ident := range expectedIdentifiers {
 		statement := program.Statements[i]
 		assertLetStatement(t, statement, ident)
+		testIntegerLiteral(t, statement.(*ast.LetStatement).Value, expectedValues[i])
+	}
+}
+
+// TestLetStatementValues verifies that the value bound by a 'let' statement is parsed as a full
+// expression, with or without a trailing semicolon.
+func TestLetStatementValues(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedName  string
+		expectedValue interface{}
+	}{
+		{"let x = 5;", "x", 5},
+		{"let y = true;", "y", true},
+		{"let foobar = y;", "foobar", "y"},
+		{"let z = 1", "z", 1},
+	}
+
+	for _, test := range tests {
+		program := parseInput(t, test.input)
+		assertNumberOfStatements(t, program, 1)
+
+		statement := program.Statements[0]
+		assertLetStatement(t, statement, test.expectedName)
+		testLiteralExpression(t, statement.(*ast.LetStatement).Value, test.expectedValue)
+	}
+
+	program := parseInput(t, "let x = 5 * y; let s = \"a${x}\"\nx")
+	assertNumberOfStatements(t, program, 3)
+	testInfixExpression(t, program.Statements[0].(*ast.LetStatement).Value, 5, "*", "y")
+	if expected := `let x = (5 * y);let s = "a${x}";x`; program.String() != expected {
+		t.Errorf("program.String() wrong. expected=%q, got=%q", expected, program.String())
 	}
 }
 
@@ -87,8 +122,45 @@ return 993322;
 	program := parseInput(t, input)
 	assertNumberOfStatements(t, program, 3)
 
-	for _, stmt := range program.Statements {
+	expectedValues := []int64{5, 10, 993322}
+	for i, stmt := range program.Statements {
 		assertReturnStatement(t, stmt)
+		testIntegerLiteral(t, stmt.(*ast.ReturnStatement).Value, expectedValues[i])
+	}
+}
+
+// TestReturnStatementValues verifies the values of 'return' statements, including bare returns,
+// and that their string form parses back to the same statements.
+func TestReturnStatementValues(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"return x + 1;", "return (x + 1);"},
+		{"return;", "return;"},
+		{"return", "return;"},
+		{"return; x", "return;x"},
+		{"if (x) { return }", "if (x) { return; }"},
+		{"if (x) { return; } else { return y }", "if (x) { return; } else { return y; }"},
+	}
+
+	for _, test := range tests {
+		program := parseInput(t, test.input)
+		if program.String() != test.expected {
+			t.Errorf("expected=%q, got=%q", test.expected, program.String())
+		}
+	}
+
+	for _, input := range []string{"return (x + 1);", "return;"} {
+		program := parseInput(t, input)
+		if program.String() != input {
+			t.Errorf("round trip changed the statement. expected=%q, got=%q", input, program.String())
+		}
+	}
+
+	program := parseInput(t, "return;")
+	if value := program.Statements[0].(*ast.ReturnStatement).Value; value != nil {
+		t.Errorf("bare return has a value: %s", value)
 	}
 }
 
@@ -114,6 +186,26 @@ func TestParserErrors(t *testing.T) {
 		{`let x 5;`},
 		{`let = 10;`},
 		{`let 838383;`},
+		{`let x = ;`},
+		{`fn(x, 1) {}`},
+		{`fn(x y) {}`},
+		{`fn(x) x`},
+		{`add(1, 2`},
+		{`add(1 2)`},
+		{`[1, 2`},
+		{`arr[1`},
+		{`arr[]`},
+		{`"a ${b c}"`},
+		{`"a ${}"`},
+		{`"a ${b`},
+		{`if (a) { b } else if { c }`},
+		{`if (a) { b } else c`},
+		{`while x { y }`},
+		{`for (let i = 0 i < 3; i) {}`},
+		{`for (x in xs {}`},
+		{`break;`},
+		{`continue`},
+		{`while (x) { fn() { continue; } }`},
 	}
 
 	for _, test := range tests {
@@ -130,6 +222,91 @@ func TestParserErrors(t *testing.T) {
 	}
 }
 
+// TestParsingSkipsComments verifies that comment tokens emitted by the lexer do not reach the grammar.
+func TestParsingSkipsComments(t *testing.T) {
+	input := `
+// the answer
+let x = 42; /* inline */
+/* before */ x // after
+`
+	l := lexer.New(input, lexer.WithComments())
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	assertNumberOfStatements(t, program, 2)
+	assertLetStatement(t, program.Statements[0], "x")
+	if program.Statements[1].String() != "x" {
+		t.Errorf("program.Statements[1].String() wrong. got=%q", program.Statements[1].String())
+	}
+}
+
+// TestParsingFromStream verifies that the parser can consume tokens lexed concurrently on another goroutine.
+func TestParsingFromStream(t *testing.T) {
+	input := `
+let x = 5;
+return x;
+if (x < y) { x } else { y }
+-a * b + c ** 2 >= 3 && !done
+`
+	expected := parseInput(t, input).String()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream := lexer.NewStream(ctx, lexer.New(input), 8)
+	p := New(stream)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if program.String() != expected {
+		t.Errorf("expected=%q, got=%q", expected, program.String())
+	}
+}
+
+// TestParsingWithDialect verifies that dialect operators and keyword aliases are parsed.
+func TestParsingWithDialect(t *testing.T) {
+	var (
+		PIPE_FORWARD = token.Register("|>")
+		SPACESHIP    = token.Register("<=>")
+		NOT          = token.Register("not")
+	)
+	dialect := token.Dialect{
+		Keywords: map[string]token.TokenType{"when": token.IF},
+		Operators: []token.Operator{
+			{Literal: "|>", Type: PIPE_FORWARD, Precedence: LOGICAL_OR},
+			{Literal: "<=>", Type: SPACESHIP, Precedence: LESSGREATER},
+			{Literal: "not", Type: NOT, Prefix: true},
+		},
+	}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"a |> f + 1", "(a |> (f + 1))"},
+		{"a <=> b + c == 0", "((a <=> (b + c)) == 0)"},
+		{"not a && b", "((nota) && b)"},
+		{"when (a) { b }", "when (a) { b }"},
+	}
+
+	for _, test := range tests {
+		l := lexer.New(test.input, lexer.WithDialect(dialect))
+		p := New(l, WithDialect(dialect))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+		if program.String() != test.expected {
+			t.Errorf("expected=%q, got=%q", test.expected, program.String())
+		}
+	}
+
+	// The dialect must not leak into parsers created without it.
+	p := New(lexer.New("a <=> b", lexer.WithDialect(dialect)))
+	p.ParseProgram()
+	if len(p.Errors()) == 0 {
+		t.Errorf("expected errors when parsing dialect operators without the dialect")
+	}
+}
+
 // ----- Tests for string representation of AST nodes -----
 
 // TestString verifies the correct string representation of AST nodes.
@@ -218,6 +395,195 @@ func TestParseIntegerLiteralExpression(t *testing.T) {
 	}
 }
 
+// TestParseIntegerLiteralBases verifies the parsing of based integer literals and digit separators.
+// Without a prefix, leading zeros do not make a literal octal.
+func TestParseIntegerLiteralBases(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"0xFF;", 255},
+		{"0o17;", 15},
+		{"0b1010;", 10},
+		{"1_000_000;", 1000000},
+		{"0x_dead_beef;", 0xdeadbeef},
+		{"0123;", 123},
+		{"09;", 9},
+		{"0_9;", 9},
+	}
+
+	for _, test := range tests {
+		program := parseInput(t, test.input)
+		assertNumberOfStatements(t, program, 1)
+
+		statement, ok := program.Statements[0].(*ast.ExpressionStatement)
+		if !ok {
+			t.Fatalf("program.Statements[0] is not *ast.ExpressionStatement. got=%T", program.Statements[0])
+		}
+		literal, ok := statement.Expression.(*ast.IntegerLiteral)
+		if !ok {
+			t.Fatalf("stmt.Expression is not *ast.IntegerLiteral. got=%T", statement.Expression)
+		}
+		if literal.Value != test.expected {
+			t.Errorf("literal.Value not %d. got=%d", test.expected, literal.Value)
+		}
+	}
+}
+
+// TestParseFloatLiteralExpression verifies the parsing of floating-point literals.
+func TestParseFloatLiteralExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected float64
+	}{
+		{"3.14;", 3.14},
+		{"1.5e-3;", 0.0015},
+		{"2E10;", 2e10},
+	}
+
+	for _, test := range tests {
+		program := parseInput(t, test.input)
+		assertNumberOfStatements(t, program, 1)
+
+		statement, ok := program.Statements[0].(*ast.ExpressionStatement)
+		if !ok {
+			t.Fatalf("program.Statements[0] is not *ast.ExpressionStatement. got=%T", program.Statements[0])
+		}
+		literal, ok := statement.Expression.(*ast.FloatLiteral)
+		if !ok {
+			t.Fatalf("stmt.Expression is not *ast.FloatLiteral. got=%T", statement.Expression)
+		}
+		if literal.Value != test.expected {
+			t.Errorf("literal.Value not %g. got=%g", test.expected, literal.Value)
+		}
+		if literal.String() != test.input[:len(test.input)-1] {
+			t.Errorf("literal.String() not %s. got=%s", test.input[:len(test.input)-1], literal.String())
+		}
+	}
+}
+
+// TestInvalidNumberLiterals verifies that out-of-range numbers are reported as parser errors, and that
+// numbers the lexer rejected as malformed are reported as illegal tokens.
+func TestInvalidNumberLiterals(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedError string
+	}{
+		{"1e400;", `could not parse "1e400" as float`},
+		{"99999999999999999999;", `could not parse "99999999999999999999" as integer`},
+		{"0x8000000000000000;", `could not parse "0x8000000000000000" as integer`},
+		{"1e+;", `illegal token "1e+"`},
+		{"0b102;", `illegal token "0b102"`},
+		{"12abc;", `illegal token "12abc"`},
+	}
+
+	for _, test := range tests {
+		p := New(lexer.New(test.input))
+		p.ParseProgram()
+		errors := p.Errors()
+		if len(errors) == 0 || errors[0] != test.expectedError {
+			t.Errorf("expected first error %q for input %q, got=%q", test.expectedError, test.input, errors)
+		}
+	}
+}
+
+// TestParseStringLiteralExpression verifies the parsing of string literals and their re-escaped string form.
+func TestParseStringLiteralExpression(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedValue  string
+		expectedString string
+	}{
+		{`"hello world";`, "hello world", `"hello world"`},
+		{`"a\tb\nc";`, "a\tb\nc", `"a\tb\nc"`},
+		{`"say \"hi\" \\o/";`, `say "hi" \o/`, `"say \"hi\" \\o/"`},
+		{`"\u{1F412}\u{7}";`, "🐒\a", `"🐒\u{7}"`},
+		{`"\${x} costs $5";`, "${x} costs $5", `"\${x} costs $5"`},
+		{"`raw\\n\nstring`;", "raw\\n\nstring", `"raw\\n\nstring"`},
+	}
+
+	for _, test := range tests {
+		program := parseInput(t, test.input)
+		assertNumberOfStatements(t, program, 1)
+
+		statement, ok := program.Statements[0].(*ast.ExpressionStatement)
+		if !ok {
+			t.Fatalf("program.Statements[0] is not *ast.ExpressionStatement. got=%T", program.Statements[0])
+		}
+		literal, ok := statement.Expression.(*ast.StringLiteral)
+		if !ok {
+			t.Fatalf("stmt.Expression is not *ast.StringLiteral. got=%T", statement.Expression)
+		}
+		if literal.Value != test.expectedValue {
+			t.Errorf("literal.Value not %q. got=%q", test.expectedValue, literal.Value)
+		}
+		if literal.String() != test.expectedString {
+			t.Errorf("literal.String() not %q. got=%q", test.expectedString, literal.String())
+		}
+
+		// The string form must lex back to the same value.
+		reparsed := parseInput(t, literal.String())
+		if reparsed.String() != literal.String() {
+			t.Errorf("round trip changed the literal. expected=%q, got=%q", literal.String(), reparsed.String())
+		}
+	}
+}
+
+// TestParseInterpolatedString verifies the parsing of strings with embedded expressions.
+func TestParseInterpolatedString(t *testing.T) {
+	program := parseInput(t, `"hello ${name}, ${1 + 2}${x}!";`)
+	assertNumberOfStatements(t, program, 1)
+
+	statement, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not *ast.ExpressionStatement. got=%T", program.Statements[0])
+	}
+	str, ok := statement.Expression.(*ast.InterpolatedString)
+	if !ok {
+		t.Fatalf("stmt.Expression is not *ast.InterpolatedString. got=%T", statement.Expression)
+	}
+	if len(str.Parts) != 6 {
+		t.Fatalf("str.Parts does not contain 6 parts. got=%d", len(str.Parts))
+	}
+
+	for i, text := range map[int]string{0: "hello ", 2: ", ", 5: "!"} {
+		literal, ok := str.Parts[i].(*ast.StringLiteral)
+		if !ok || literal.Value != text {
+			t.Errorf("str.Parts[%d] is not the text %q. got=%s", i, text, str.Parts[i])
+		}
+	}
+	testIdentifier(t, str.Parts[1], "name")
+	testInfixExpression(t, str.Parts[3], 1, "+", 2)
+	testIdentifier(t, str.Parts[4], "x")
+
+	if expected := `"hello ${name}, ${(1 + 2)}${x}!"`; str.String() != expected {
+		t.Errorf("str.String() not %q. got=%q", expected, str.String())
+	}
+}
+
+// TestParseNestedInterpolatedString verifies that interpolated strings can be nested and that
+// their string form parses back to the same tree.
+func TestParseNestedInterpolatedString(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`"a ${"b ${c} d"} e"`, `"a ${"b ${c} d"} e"`},
+		{`"${-x}${"\${"}"`, `"${(-x)}${"\${"}"`},
+		{`"${ "x" } ${"y"}"`, `"${"x"} ${"y"}"`},
+	}
+
+	for _, test := range tests {
+		program := parseInput(t, test.input)
+		if program.String() != test.expected {
+			t.Errorf("expected=%q, got=%q", test.expected, program.String())
+		}
+		if reparsed := parseInput(t, program.String()); reparsed.String() != program.String() {
+			t.Errorf("round trip changed the string. expected=%q, got=%q", program.String(), reparsed.String())
+		}
+	}
+}
+
 // TestParsingPrefixExpressions tests the parsing of prefix expressions
 // such as ! and -.
 func TestParsePrefixExpressions(t *testing.T) {
@@ -285,6 +651,17 @@ func TestParsingInfixExpressions(t *testing.T) {
 		{"foobar < barfoo;", "foobar", "<", "barfoo"},
 		{"foobar == barfoo;", "foobar", "==", "barfoo"},
 		{"foobar != barfoo;", "foobar", "!=", "barfoo"},
+		{"5 % 5;", 5, "%", 5},
+		{"5 ** 5;", 5, "**", 5},
+		{"5 >= 5;", 5, ">=", 5},
+		{"5 <= 5;", 5, "<=", 5},
+		{"5 & 5;", 5, "&", 5},
+		{"5 | 5;", 5, "|", 5},
+		{"5 ^ 5;", 5, "^", 5},
+		{"5 << 5;", 5, "<<", 5},
+		{"5 >> 5;", 5, ">>", 5},
+		{"true && false;", true, "&&", false},
+		{"true || false;", true, "||", false},
 		{"true == true", true, "==", true},
 		{"true != false", true, "!=", false},
 		{"false == false", false, "==", false},
@@ -408,6 +785,106 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 			"!(true == true)",
 			"(!(true == true))",
 		},
+		{
+			"a || b && c",
+			"(a || (b && c))",
+		},
+		{
+			"a && b || c && d",
+			"((a && b) || (c && d))",
+		},
+		{
+			"a < b && c >= d",
+			"((a < b) && (c >= d))",
+		},
+		{
+			"a <= b == c > d",
+			"((a <= b) == (c > d))",
+		},
+		{
+			"a & b == c",
+			"((a & b) == c)",
+		},
+		{
+			"a | b ^ c & d",
+			"((a | b) ^ (c & d))",
+		},
+		{
+			"a + b << c % d",
+			"(a + ((b << c) % d))",
+		},
+		{
+			"a ** b ** c",
+			"(a ** (b ** c))",
+		},
+		{
+			"a * b ** c",
+			"(a * (b ** c))",
+		},
+		{
+			"-a ** b",
+			"(-(a ** b))",
+		},
+		{
+			"a ** -b",
+			"(a ** (-b))",
+		},
+		{
+			"a + add(b * c) + d",
+			"((a + add((b * c))) + d)",
+		},
+		{
+			"add(a, b, 1, 2 * 3, 4 + 5, add(6, 7 * 8))",
+			"add(a, b, 1, (2 * 3), (4 + 5), add(6, (7 * 8)))",
+		},
+		{
+			"add(a + b + c * d / f + g)",
+			"add((((a + b) + ((c * d) / f)) + g))",
+		},
+		{
+			"-f(x) ** 2",
+			"(-(f(x) ** 2))",
+		},
+		{
+			"a * [1, 2, 3, 4][b * c] * d",
+			"((a * ([1, 2, 3, 4][(b * c)])) * d)",
+		},
+		{
+			"add(a * b[2], b[1], 2 * [1, 2][1])",
+			"add((a * (b[2])), (b[1]), (2 * ([1, 2][1])))",
+		},
+		{
+			"fns[0](x)",
+			"(fns[0])(x)",
+		},
+		{
+			"f(x)[0]",
+			"(f(x)[0])",
+		},
+		{
+			"-a[i] ** 2",
+			"(-((a[i]) ** 2))",
+		},
+		{
+			"a = b = c",
+			"(a = (b = c))",
+		},
+		{
+			"x += y * 2",
+			"(x += (y * 2))",
+		},
+		{
+			"a[i] = b || c && d",
+			"((a[i]) = (b || (c && d)))",
+		},
+		{
+			"x -= y = z == 1",
+			"(x -= (y = (z == 1)))",
+		},
+		{
+			"f(x = 1, y)",
+			"f((x = 1), y)",
+		},
 	}
 	for _, tt := range tests {
 		l := lexer.New(tt.input)
@@ -421,6 +898,109 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 	}
 }
 
+// TestShortCircuitOperators verifies that only && and || are marked as short-circuiting.
+func TestShortCircuitOperators(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"a && b", true},
+		{"a || b", true},
+		{"a & b", false},
+		{"a | b", false},
+		{"a == b", false},
+	}
+
+	for _, test := range tests {
+		program := parseInput(t, test.input)
+		assertNumberOfStatements(t, program, 1)
+
+		statement := program.Statements[0].(*ast.ExpressionStatement)
+		infix, ok := statement.Expression.(*ast.InfixExpression)
+		if !ok {
+			t.Fatalf("statement.Expression is not *ast.InfixExpression. got=%T", statement.Expression)
+		}
+		if infix.ShortCircuit != test.expected {
+			t.Errorf("%q - infix.ShortCircuit not %t. got=%t", test.input, test.expected, infix.ShortCircuit)
+		}
+	}
+}
+
+// TestAssignExpressions verifies the target, operator and value of assignments.
+func TestAssignExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		target   string
+		operator string
+		value    interface{}
+	}{
+		{"x = 5;", "x", "=", 5},
+		{"x += 1", "x", "+=", 1},
+		{"x -= y", "x", "-=", "y"},
+		{"x *= 2", "x", "*=", 2},
+		{"x /= 2", "x", "/=", 2},
+		{"x %= 2", "x", "%=", 2},
+		{"a[0] = true", "(a[0])", "=", true},
+		{"h[\"k\"] += 1", "(h[\"k\"])", "+=", 1},
+	}
+
+	for _, test := range tests {
+		program := parseInput(t, test.input)
+		assertNumberOfStatements(t, program, 1)
+
+		statement := program.Statements[0].(*ast.ExpressionStatement)
+		assign, ok := statement.Expression.(*ast.AssignExpression)
+		if !ok {
+			t.Fatalf("statement.Expression is not *ast.AssignExpression. got=%T", statement.Expression)
+		}
+		if assign.Target.String() != test.target {
+			t.Errorf("%q - target wrong. expected=%q, got=%q", test.input, test.target, assign.Target.String())
+		}
+		if assign.Operator != test.operator {
+			t.Errorf("%q - operator wrong. expected=%q, got=%q", test.input, test.operator, assign.Operator)
+		}
+		testLiteralExpression(t, assign.Value, test.value)
+	}
+}
+
+// TestInvalidAssignTargets verifies that only identifiers and index expressions can be assigned to.
+func TestInvalidAssignTargets(t *testing.T) {
+	tests := []struct {
+		input    string
+		code     ErrorCode
+		expected string
+	}{
+		{"1 = 2", ErrInvalidAssignment, "cannot assign to 1"},
+		{"f() = 3", ErrInvalidAssignment, "cannot assign to f()"},
+		{"a + b += 1", ErrInvalidAssignment, "cannot assign to (a + b)"},
+		{"(x = 1) = 2", ErrInvalidAssignment, "cannot assign to (x = 1)"},
+		{"-x = 1", ErrInvalidAssignment, "cannot assign to (-x)"},
+		// Targets that fail to parse are only reported once.
+		{"@ = 1", ErrIllegalToken, `illegal token "@"`},
+		{"@ += 2", ErrIllegalToken, `illegal token "@"`},
+		{"99999999999999999999 = 1", ErrInvalidNumber, `could not parse "99999999999999999999" as integer`},
+		{"-@ = 1", ErrIllegalToken, `illegal token "@"`},
+		{"1 + @ = 2", ErrIllegalToken, `illegal token "@"`},
+		{"0x + 1 = 2", ErrIllegalToken, `illegal token "0x"`},
+		{"f(@) = 1", ErrIllegalToken, `illegal token "@"`},
+		{"a = ) += 1", ErrMissingExpression, "no prefix parse function for ) found"},
+	}
+
+	for _, test := range tests {
+		p := New(lexer.New(test.input))
+		p.ParseProgram()
+
+		errors := p.ParseErrors()
+		if len(errors) != 1 {
+			t.Fatalf("%q - expected 1 error, got %d: %q", test.input, len(errors), p.Errors())
+		}
+		if errors[0].Code != test.code || errors[0].Msg != test.expected {
+			t.Errorf("%q - error wrong. expected=%s %q, got=%s %q", test.input,
+				test.code, test.expected, errors[0].Code, errors[0].Msg)
+		}
+	}
+}
+
 func TestParsingBooleanExpressions(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -547,6 +1127,402 @@ func TestIfElseExpression(t *testing.T) {
 	}
 }
 
+// TestElseIfExpression verifies that an else-if chain nests each if in the alternative of the previous one.
+func TestElseIfExpression(t *testing.T) {
+	program := parseInput(t, `if (a) { x } else if (b) { y } else if (c) { z } else { w }`)
+	assertNumberOfStatements(t, program, 1)
+
+	statement, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("Expected *ast.ExpressionStatement, but got %T", program.Statements[0])
+	}
+	exp, ok := statement.Expression.(*ast.IfExpression)
+	if !ok {
+		t.Fatalf("Expected *ast.IfExpression, but got %T", statement.Expression)
+	}
+
+	for _, want := range []struct{ condition, consequence string }{{"a", "x"}, {"b", "y"}, {"c", "z"}} {
+		testIdentifier(t, exp.Condition, want.condition)
+		if len(exp.Consequence.Statements) != 1 {
+			t.Fatalf("Expected 1 statement in the consequence, but got %d", len(exp.Consequence.Statements))
+		}
+		testIdentifier(t, exp.Consequence.Statements[0].(*ast.ExpressionStatement).Expression, want.consequence)
+
+		if want.condition == "c" {
+			break
+		}
+		next := exp.ElseIf()
+		if next == nil {
+			t.Fatalf("Expected an else-if after %q, but got %v", want.condition, exp.Alternative)
+		}
+		exp = next
+	}
+
+	if exp.ElseIf() != nil {
+		t.Fatalf("Expected a plain else block at the end of the chain")
+	}
+	if len(exp.Alternative.Statements) != 1 {
+		t.Fatalf("Expected 1 statement in the alternative, but got %d", len(exp.Alternative.Statements))
+	}
+	testIdentifier(t, exp.Alternative.Statements[0].(*ast.ExpressionStatement).Expression, "w")
+}
+
+// TestIfExpressionString verifies that if expressions print back to source that parses to the same tree.
+func TestIfExpressionString(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"if (x) {}", "if (x) {}"},
+		{"if (x < y) { x } else { y }", "if ((x < y)) { x } else { y }"},
+		{"if (a) { x; y } else if (b) { let z = 1; z }", "if (a) { x; y } else if (b) { let z = 1; z }"},
+		{"if (a) { x } else if (b) { y } else { z }", "if (a) { x } else if (b) { y } else { z }"},
+		{"if (a) { x } else { if (b) { y } }", "if (a) { x } else { if (b) { y } }"},
+		{"let v = if (a) { 1 } else if (b) { 2 };", "let v = if (a) { 1 } else if (b) { 2 };"},
+		{"if (a) { fn(x) { x } } else { fn() { if (b) { c } } }", "if (a) { fn(x) { x } } else { fn() { if (b) { c } } }"},
+		{"if (a) { let f = fn(x, y) { let z = x; z + y }; f }", "if (a) { let f = fn(x, y) { let z = x; (z + y) }; f }"},
+	}
+
+	for _, tt := range tests {
+		program := parseInput(t, tt.input)
+		actual := program.String()
+		if actual != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, actual)
+		}
+		if reparsed := parseInput(t, actual).String(); reparsed != actual {
+			t.Errorf("%q does not reparse to itself, got=%q", actual, reparsed)
+		}
+	}
+}
+
+// ----- Tests for loops -----
+
+// TestWhileStatement verifies the parsing of a while loop's condition and body.
+func TestWhileStatement(t *testing.T) {
+	program := parseInput(t, `while (x < y) { x; break; }`)
+	assertNumberOfStatements(t, program, 1)
+
+	statement, ok := program.Statements[0].(*ast.WhileStatement)
+	if !ok {
+		t.Fatalf("Expected *ast.WhileStatement, but got %T", program.Statements[0])
+	}
+	testInfixExpression(t, statement.Condition, "x", "<", "y")
+	if len(statement.Body.Statements) != 2 {
+		t.Fatalf("Expected 2 statements in the body, but got %d", len(statement.Body.Statements))
+	}
+	if _, ok := statement.Body.Statements[1].(*ast.BreakStatement); !ok {
+		t.Fatalf("Expected *ast.BreakStatement, but got %T", statement.Body.Statements[1])
+	}
+}
+
+// TestForStatement verifies the parsing of the three clauses of a C-style for loop.
+func TestForStatement(t *testing.T) {
+	program := parseInput(t, `for (let i = 0; i < n; i + 1) { continue }`)
+	assertNumberOfStatements(t, program, 1)
+
+	statement, ok := program.Statements[0].(*ast.ForStatement)
+	if !ok {
+		t.Fatalf("Expected *ast.ForStatement, but got %T", program.Statements[0])
+	}
+	assertLetStatement(t, statement.Init, "i")
+	testInfixExpression(t, statement.Condition, "i", "<", "n")
+	testInfixExpression(t, statement.Update, "i", "+", 1)
+	if len(statement.Body.Statements) != 1 {
+		t.Fatalf("Expected 1 statement in the body, but got %d", len(statement.Body.Statements))
+	}
+	if _, ok := statement.Body.Statements[0].(*ast.ContinueStatement); !ok {
+		t.Fatalf("Expected *ast.ContinueStatement, but got %T", statement.Body.Statements[0])
+	}
+}
+
+// TestForInStatement verifies the parsing of the variable and collection of a for-in loop.
+func TestForInStatement(t *testing.T) {
+	program := parseInput(t, `for (x in [1, 2]) { x }`)
+	assertNumberOfStatements(t, program, 1)
+
+	statement, ok := program.Statements[0].(*ast.ForInStatement)
+	if !ok {
+		t.Fatalf("Expected *ast.ForInStatement, but got %T", program.Statements[0])
+	}
+	testIdentifier(t, statement.Variable, "x")
+	if _, ok := statement.Iterable.(*ast.ArrayLiteral); !ok {
+		t.Fatalf("Expected *ast.ArrayLiteral, but got %T", statement.Iterable)
+	}
+	if len(statement.Body.Statements) != 1 {
+		t.Fatalf("Expected 1 statement in the body, but got %d", len(statement.Body.Statements))
+	}
+}
+
+// TestLoopString verifies the string form of loops, including loops nested in function literals.
+func TestLoopString(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"while (true) {}", "while (true) {}"},
+		{"while (x) { if (y) { break } x; continue; }", "while (x) { if (y) { break; }; x; continue; }"},
+		{"for (let i = 0; i < 3; i + 1) { f(i) }", "for (let i = 0; (i < 3); (i + 1)) { f(i) }"},
+		{"for (i; i; i) {}", "for (i; i; i) {}"},
+		{"for (;;) { break }", "for (; ; ) { break; }"},
+		{"for (let i = 0; i < n; i = i + 1) { s += i }", "for (let i = 0; (i < n); (i = (i + 1))) { (s += i) }"},
+		{"for (; x;) {}", "for (; x; ) {}"},
+		{"for (k in keys(h)) { for (v in h[k]) { v } }", "for (k in keys(h)) { for (v in (h[k])) { v } }"},
+		{"while (a) { let f = fn() { while (b) { break } }; }", "while (a) { let f = fn() { while (b) { break; } }; }"},
+	}
+
+	for _, tt := range tests {
+		program := parseInput(t, tt.input)
+		actual := program.String()
+		if actual != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, actual)
+		}
+	}
+}
+
+// ----- Tests for functions -----
+
+// TestFunctionLiteralParsing verifies the parsing of a function literal's parameters and body.
+func TestFunctionLiteralParsing(t *testing.T) {
+	program := parseInput(t, `fn(x, y) { x + y; }`)
+	assertNumberOfStatements(t, program, 1)
+
+	statement, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not *ast.ExpressionStatement. got=%T", program.Statements[0])
+	}
+	function, ok := statement.Expression.(*ast.FunctionLiteral)
+	if !ok {
+		t.Fatalf("stmt.Expression is not *ast.FunctionLiteral. got=%T", statement.Expression)
+	}
+
+	if len(function.Parameters) != 2 {
+		t.Fatalf("function literal parameters wrong. want 2, got=%d", len(function.Parameters))
+	}
+	testLiteralExpression(t, function.Parameters[0], "x")
+	testLiteralExpression(t, function.Parameters[1], "y")
+
+	if len(function.Body.Statements) != 1 {
+		t.Fatalf("function.Body.Statements has not 1 statement. got=%d", len(function.Body.Statements))
+	}
+	bodyStatement, ok := function.Body.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("function body statement is not *ast.ExpressionStatement. got=%T", function.Body.Statements[0])
+	}
+	testInfixExpression(t, bodyStatement.Expression, "x", "+", "y")
+}
+
+// TestFunctionParameterParsing verifies the parsing of empty and multiple parameter lists.
+func TestFunctionParameterParsing(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedParams []string
+	}{
+		{"fn() {};", []string{}},
+		{"fn(x) {};", []string{"x"}},
+		{"fn(x, y, z) {};", []string{"x", "y", "z"}},
+	}
+
+	for _, test := range tests {
+		program := parseInput(t, test.input)
+		statement := program.Statements[0].(*ast.ExpressionStatement)
+		function := statement.Expression.(*ast.FunctionLiteral)
+
+		if len(function.Parameters) != len(test.expectedParams) {
+			t.Errorf("length parameters wrong. want %d, got=%d", len(test.expectedParams), len(function.Parameters))
+		}
+		for i, ident := range test.expectedParams {
+			testLiteralExpression(t, function.Parameters[i], ident)
+		}
+	}
+}
+
+// TestCallExpressionParsing verifies the parsing of a call's function and arguments.
+func TestCallExpressionParsing(t *testing.T) {
+	program := parseInput(t, "add(1, 2 * 3, 4 + 5);")
+	assertNumberOfStatements(t, program, 1)
+
+	statement, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not *ast.ExpressionStatement. got=%T", program.Statements[0])
+	}
+	call, ok := statement.Expression.(*ast.CallExpression)
+	if !ok {
+		t.Fatalf("stmt.Expression is not *ast.CallExpression. got=%T", statement.Expression)
+	}
+	if !testIdentifier(t, call.Function, "add") {
+		return
+	}
+
+	if len(call.Arguments) != 3 {
+		t.Fatalf("wrong length of arguments. got=%d", len(call.Arguments))
+	}
+	testLiteralExpression(t, call.Arguments[0], 1)
+	testInfixExpression(t, call.Arguments[1], 2, "*", 3)
+	testInfixExpression(t, call.Arguments[2], 4, "+", 5)
+}
+
+// TestCallingFunctionLiteral verifies that a function literal can be called directly, and the
+// string form of functions and calls.
+func TestCallingFunctionLiteral(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"fn(x, y) { x + y }(1, 2)", "fn(x, y) { (x + y) }(1, 2)"},
+		{"let add = fn(a, b) { return a + b; }; add(1, 2);", "let add = fn(a, b) { return (a + b); };add(1, 2)"},
+		{"f()()", "f()()"},
+	}
+
+	for _, test := range tests {
+		program := parseInput(t, test.input)
+		if program.String() != test.expected {
+			t.Errorf("expected=%q, got=%q", test.expected, program.String())
+		}
+	}
+}
+
+// ----- Tests for arrays -----
+
+// TestParsingArrayLiterals verifies the parsing of array literals and their elements.
+func TestParsingArrayLiterals(t *testing.T) {
+	program := parseInput(t, "[1, 2 * 2, f(x)]")
+
+	statement, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not *ast.ExpressionStatement. got=%T", program.Statements[0])
+	}
+	array, ok := statement.Expression.(*ast.ArrayLiteral)
+	if !ok {
+		t.Fatalf("stmt.Expression is not *ast.ArrayLiteral. got=%T", statement.Expression)
+	}
+
+	if len(array.Elements) != 3 {
+		t.Fatalf("len(array.Elements) not 3. got=%d", len(array.Elements))
+	}
+	testIntegerLiteral(t, array.Elements[0], 1)
+	testInfixExpression(t, array.Elements[1], 2, "*", 2)
+	if array.Elements[2].String() != "f(x)" {
+		t.Errorf("array.Elements[2] not f(x). got=%s", array.Elements[2])
+	}
+}
+
+// TestParsingEmptyArrayLiteral verifies that [] parses as an array without elements.
+func TestParsingEmptyArrayLiteral(t *testing.T) {
+	program := parseInput(t, "[]")
+
+	statement := program.Statements[0].(*ast.ExpressionStatement)
+	array, ok := statement.Expression.(*ast.ArrayLiteral)
+	if !ok {
+		t.Fatalf("stmt.Expression is not *ast.ArrayLiteral. got=%T", statement.Expression)
+	}
+	if len(array.Elements) != 0 {
+		t.Errorf("len(array.Elements) not 0. got=%d", len(array.Elements))
+	}
+}
+
+// TestParsingIndexExpressions verifies the parsing of the indexed expression and of the index.
+func TestParsingIndexExpressions(t *testing.T) {
+	program := parseInput(t, "arr[i + 1]")
+
+	statement, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not *ast.ExpressionStatement. got=%T", program.Statements[0])
+	}
+	index, ok := statement.Expression.(*ast.IndexExpression)
+	if !ok {
+		t.Fatalf("stmt.Expression is not *ast.IndexExpression. got=%T", statement.Expression)
+	}
+	if !testIdentifier(t, index.Left, "arr") {
+		return
+	}
+	testInfixExpression(t, index.Index, "i", "+", 1)
+}
+
+// ----- Tests for hashes -----
+
+// TestParsingHashLiterals verifies that hash literals keep their pairs in source order, whatever
+// the expressions used as keys.
+func TestParsingHashLiterals(t *testing.T) {
+	program := parseInput(t, `{"a": 1, key: value, 2: true, "b" + c: 10 - 8}`)
+
+	statement, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not *ast.ExpressionStatement. got=%T", program.Statements[0])
+	}
+	hash, ok := statement.Expression.(*ast.HashLiteral)
+	if !ok {
+		t.Fatalf("stmt.Expression is not *ast.HashLiteral. got=%T", statement.Expression)
+	}
+
+	expected := []struct {
+		key   string
+		value string
+	}{
+		{`"a"`, "1"},
+		{"key", "value"},
+		{"2", "true"},
+		{`("b" + c)`, "(10 - 8)"},
+	}
+	if len(hash.Pairs) != len(expected) {
+		t.Fatalf("hash.Pairs has wrong length. want %d, got=%d", len(expected), len(hash.Pairs))
+	}
+	for i, pair := range expected {
+		if hash.Pairs[i].Key.String() != pair.key || hash.Pairs[i].Value.String() != pair.value {
+			t.Errorf("hash.Pairs[%d] wrong. want %s: %s, got=%s: %s", i, pair.key, pair.value,
+				hash.Pairs[i].Key, hash.Pairs[i].Value)
+		}
+	}
+
+	if expectedString := `{"a": 1, key: value, 2: true, ("b" + c): (10 - 8)}`; hash.String() != expectedString {
+		t.Errorf("hash.String() wrong. expected=%q, got=%q", expectedString, hash.String())
+	}
+}
+
+// TestParsingHashLiteralsInContext verifies that braces in expression position open hash literals
+// while the braces after if and fn still open blocks.
+func TestParsingHashLiteralsInContext(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"{}", "{}"},
+		{"let h = {1: {2: [3]}};", "let h = {1: {2: [3]}};"},
+		{`{"f": fn(x) { x }}["f"](1)`, `({"f": fn(x) { x }}["f"])(1)`},
+		{"if (x) { y }", "if (x) { y }"},
+		{"if (x) { {y: 1} }", "if (x) { {y: 1} }"},
+		{`"${ {"a": 1}["a"] }"`, `"${({"a": 1}["a"])}"`},
+	}
+
+	for _, test := range tests {
+		program := parseInput(t, test.input)
+		if program.String() != test.expected {
+			t.Errorf("expected=%q, got=%q", test.expected, program.String())
+		}
+	}
+}
+
+// TestHashLiteralErrors verifies the errors reported for malformed pairs.
+func TestHashLiteralErrors(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedError string
+	}{
+		{`{"a" 1}`, `expected : after hash key "a", got INT instead`},
+		{`{"a": 1 "b": 2}`, `expected , or } after the value of hash key "a", got STRING instead`},
+		{`{"a": 1, }`, "no prefix parse function for } found"},
+		{`{"a": 1`, "unclosed {: expected }, got EOF instead"},
+	}
+
+	for _, test := range tests {
+		p := New(lexer.New(test.input))
+		p.ParseProgram()
+		errors := p.Errors()
+		if len(errors) == 0 || errors[0] != test.expectedError {
+			t.Errorf("expected first error %q for input %q, got=%q", test.expectedError, test.input, errors)
+		}
+	}
+}
+
 // ----- Helper functions -----
 
 // testInfixExpression checks if an expression is an InfixExpression
@@ -672,3 +1648,24 @@ func testBooleanLiteral(t *testing.T, exp ast.Expression, value bool) bool {
 
 	return true
 }
+
+// benchmarkInput is a large Monkey source file, about 1 MB, made of statements the parser handles.
+var benchmarkInput = strings.Repeat(`// compute a few things
+let total = 0x_FF + 1_000 * (count - 1) / 3.5e2;
+"hello, ${name}! you have ${count} new messages\n";
+if (total >= 10 && !done || retries != 3) { return total ** 2 % 7; } else { return false; }
+(mask << 4) | (flags & 0b1010) ^ value >> 1 == -total;
+`, 4000)
+
+// BenchmarkParseProgram measures the throughput of lexing and parsing a large input.
+func BenchmarkParseProgram(b *testing.B) {
+	b.SetBytes(int64(len(benchmarkInput)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		p := New(lexer.New(benchmarkInput))
+		p.ParseProgram()
+		if len(p.Errors()) != 0 {
+			b.Fatalf("unexpected errors: %v", p.Errors())
+		}
+	}
+}
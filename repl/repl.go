@@ -39,7 +39,7 @@ func Start(in io.Reader, out io.Writer) {
 		l := lexer.New(line)
 
 		for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
-			fmt.Fprintf(out, "%+v\n", tok)
+			fmt.Fprintf(out, "{Type:%s Literal:%s}\n", tok.Type, tok.Literal)
 		}
 	}
 }
@@ -32,12 +32,10 @@ func TestREPL_IllegalToken(t *testing.T) {
 
 	Start(in, &out)
 
-	expectedOutput := `🐒💻>> {Type:ILLEGAL Literal:@}
-{Type:ILLEGAL Literal:#}
-{Type:ILLEGAL Literal:$}
-{Type:ILLEGAL Literal:%}
-{Type:ILLEGAL Literal:^}
-{Type:ILLEGAL Literal:&}
+	expectedOutput := `🐒💻>> {Type:ILLEGAL Literal:@#$}
+{Type:% Literal:%}
+{Type:^ Literal:^}
+{Type:& Literal:&}
 🐒💻>> `
 	gotOutput := out.String()
 
@@ -0,0 +1,33 @@
+package token
+
+// Dialect extends the Monkey language with extra keywords and operators, for embedders that use
+// Monkey as the base of a domain-specific language. Pass the same Dialect to the lexer and to the
+// parser (see lexer.WithDialect and parser.WithDialect).
+type Dialect struct {
+	// Keywords maps extra reserved words to their token types. A keyword may map to one of Monkey's
+	// own token types, e.g. "when" to IF, to act as an alias, or to a new type defined by the embedder.
+	// Dialect keywords take precedence over Monkey's own.
+	Keywords map[string]TokenType
+
+	// Operators lists extra operator spellings.
+	Operators []Operator
+}
+
+// Operator describes an operator spelling added by a Dialect and how the parser handles it.
+type Operator struct {
+	// Literal is the operator as written in the source, such as "|>". Spellings made of punctuation are
+	// matched longest first against Monkey's own operators; spellings that look like identifiers, such
+	// as "and", are reserved as keywords. Spellings starting with "//" or "/*" are read as comments.
+	Literal string
+
+	// Type is the token type the lexer emits for the operator.
+	Type TokenType
+
+	// Precedence is the binding power of the operator when used as an infix operator, on the scale
+	// of the parser's precedence levels (parser.LOWEST, parser.SUM, ...). Zero means the operator
+	// is not an infix operator.
+	Precedence int
+
+	// Prefix reports whether the operator may be used as a prefix operator, like ! and -.
+	Prefix bool
+}
@@ -1,67 +1,304 @@
 // Package token defines the set of lexical tokens for the Monkey programming language.
 package token
 
-// TokenType represents the type of a lexical token.
-type TokenType string
+import (
+	"fmt"
+	"strconv"
+	"sync"
+)
+
+// TokenType represents the type of a lexical token. Token types are small integers, cheap to
+// compare and to use as map keys; their String method gives their name.
+type TokenType int
 
-// Token represents a lexical token with a type and literal string value.
+// Token represents a lexical token with a type, literal string value and source position.
+// Raw holds the token exactly as spelled in the source, which differs from Literal for
+// string literals, whose escape sequences are decoded. Both refer to the lexer's input
+// rather than copying it, except for the decoded value of strings with escape sequences.
+//
+// Leading and Trailing are only filled in by lossless lexers (see lexer.WithTrivia). They hold
+// the whitespace and comments around the token: Trailing runs up to the end of the token's line,
+// and Leading holds everything between the previous token's trailing trivia and this token.
 type Token struct {
 	Type    TokenType
 	Literal string
+	Pos     Position
+
+	Raw      string
+	Leading  string
+	Trailing string
+}
+
+// Source returns the token with its trivia, as it appears in the source. Concatenating the
+// source of every token produced by a lossless lexer reproduces its input byte for byte.
+func (t Token) Source() string {
+	return t.Leading + t.Raw + t.Trailing
+}
+
+// Position describes a location in Monkey source code.
+type Position struct {
+	Filename string // name of the source file, empty if unknown
+	Offset   int    // byte offset, starting at 0
+	Line     int    // line number, starting at 1
+	Column   int    // column number in characters, starting at 1
+}
+
+// IsValid reports whether the position has been set.
+func (p Position) IsValid() bool {
+	return p.Line > 0
+}
+
+// String returns the position formatted as "file:line:column", or "line:column" when
+// no filename is known.
+func (p Position) String() string {
+	if !p.IsValid() {
+		return "-"
+	}
+	if p.Filename == "" {
+		return fmt.Sprintf("%d:%d", p.Line, p.Column)
+	}
+	return fmt.Sprintf("%s:%d:%d", p.Filename, p.Line, p.Column)
 }
 
 const (
 	// ILLEGAL represents a token/character that we don't know how to handle.
-	ILLEGAL = "ILLEGAL"
+	ILLEGAL TokenType = iota
 
 	// EOF signals the end of parsing, representing the end of our input.
-	EOF = "EOF"
+	EOF
+
+	// COMMENT holds a line or block comment. Comments are only emitted by lexers asked to keep them.
+	COMMENT // // ... or /* ... */
 
-	// IDENT and INT are used for user-defined identifiers (e.g. variable names) and integer literals.
-	IDENT = "IDENT" // add, foobar, x, y, ...
-	INT   = "INT"   // 1343456789
+	// IDENT, INT, FLOAT and STRING are used for user-defined identifiers (e.g. variable names),
+	// integer literals, floating-point literals and string literals.
+	IDENT  // add, foobar, x, y, ...
+	INT    // 1343456789
+	FLOAT  // 3.14, 1.5e-3
+	STRING // "foo bar", `foo bar`
+
+	// STRING_START, STRING_MIDDLE and STRING_END hold the text around the expressions embedded in an
+	// interpolated string: "a${x}b${y}c" is lexed as STRING_START "a", the tokens of x,
+	// STRING_MIDDLE "b", the tokens of y and STRING_END "c".
+	STRING_START  // "a${
+	STRING_MIDDLE // }b${
+	STRING_END    // }c"
 
 	// Operators
-	ASSIGN   = "="
-	PLUS     = "+"
-	MINUS    = "-"
-	BANG     = "!"
-	ASTERISK = "*"
-	SLASH    = "/"
-	LT       = "<"
-	GT       = ">"
+	ASSIGN
+	PLUS_ASSIGN
+	MINUS_ASSIGN
+	ASTERISK_ASSIGN
+	SLASH_ASSIGN
+	PERCENT_ASSIGN
+	PLUS
+	MINUS
+	BANG
+	ASTERISK
+	SLASH
+	PERCENT
+	POWER
+	LT
+	GT
+	LT_EQ
+	GT_EQ
+	AMPERSAND
+	PIPE
+	CARET
+	SHIFT_LEFT
+	SHIFT_RIGHT
+	AND
+	OR
 
 	// Delimiters such as comma, semicolon, and various brackets.
-	COMMA     = ","
-	SEMICOLON = ";"
-	LPAREN    = "("
-	RPAREN    = ")"
-	LBRACE    = "{"
-	RBRACE    = "}"
+	COMMA
+	SEMICOLON
+	COLON
+	LPAREN
+	RPAREN
+	LBRACE
+	RBRACE
+	LBRACKET
+	RBRACKET
 
 	// Keywords
-	FUNCTION = "FUNCTION"
-	LET      = "LET"
-	TRUE     = "TRUE"
-	FALSE    = "FALSE"
-	IF       = "IF"
-	ELSE     = "ELSE"
-	RETURN   = "RETURN"
+	FUNCTION
+	LET
+	TRUE
+	FALSE
+	IF
+	ELSE
+	RETURN
+	WHILE
+	FOR
+	IN
+	BREAK
+	CONTINUE
 
 	// EQ and NOT_EQ are used for equality checking.
-	EQ     = "=="
-	NOT_EQ = "!="
+	EQ
+	NOT_EQ
+
+	// numTokenTypes is the number of Monkey's own token types. Types registered by embedders follow.
+	numTokenTypes
 )
 
+// names holds the names of Monkey's own token types: the spelling of operators and delimiters, and
+// an upper-case name for the other types.
+var names = [numTokenTypes]string{
+	ILLEGAL:         "ILLEGAL",
+	EOF:             "EOF",
+	COMMENT:         "COMMENT",
+	IDENT:           "IDENT",
+	INT:             "INT",
+	FLOAT:           "FLOAT",
+	STRING:          "STRING",
+	STRING_START:    "STRING_START",
+	STRING_MIDDLE:   "STRING_MIDDLE",
+	STRING_END:      "STRING_END",
+	ASSIGN:          "=",
+	PLUS_ASSIGN:     "+=",
+	MINUS_ASSIGN:    "-=",
+	ASTERISK_ASSIGN: "*=",
+	SLASH_ASSIGN:    "/=",
+	PERCENT_ASSIGN:  "%=",
+	PLUS:            "+",
+	MINUS:           "-",
+	BANG:            "!",
+	ASTERISK:        "*",
+	SLASH:           "/",
+	PERCENT:         "%",
+	POWER:           "**",
+	LT:              "<",
+	GT:              ">",
+	LT_EQ:           "<=",
+	GT_EQ:           ">=",
+	AMPERSAND:       "&",
+	PIPE:            "|",
+	CARET:           "^",
+	SHIFT_LEFT:      "<<",
+	SHIFT_RIGHT:     ">>",
+	AND:             "&&",
+	OR:              "||",
+	COMMA:           ",",
+	SEMICOLON:       ";",
+	COLON:           ":",
+	LPAREN:          "(",
+	RPAREN:          ")",
+	LBRACE:          "{",
+	RBRACE:          "}",
+	LBRACKET:        "[",
+	RBRACKET:        "]",
+	FUNCTION:        "FUNCTION",
+	LET:             "LET",
+	TRUE:            "TRUE",
+	FALSE:           "FALSE",
+	IF:              "IF",
+	ELSE:            "ELSE",
+	RETURN:          "RETURN",
+	WHILE:           "WHILE",
+	FOR:             "FOR",
+	IN:              "IN",
+	BREAK:           "BREAK",
+	CONTINUE:        "CONTINUE",
+	EQ:              "==",
+	NOT_EQ:          "!=",
+}
+
+var (
+	registryMu sync.RWMutex
+	registered []string // names of the types created by Register, in order
+)
+
+// Register creates a new token type, distinct from Monkey's own and from every other registered
+// type, for embedders that extend the language with a Dialect. The String method of the new type
+// returns name. Register is meant to be called during initialization, as in
+//
+//	var PIPE_FORWARD = token.Register("|>")
+func Register(name string) TokenType {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registered = append(registered, name)
+	return numTokenTypes + TokenType(len(registered)-1)
+}
+
+// String returns the name of the token type, such as "IDENT", "LET" or "==".
+func (t TokenType) String() string {
+	if t >= 0 && t < numTokenTypes {
+		return names[t]
+	}
+
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	if i := int(t - numTokenTypes); i >= 0 && i < len(registered) {
+		return registered[i]
+	}
+	return "TokenType(" + strconv.Itoa(int(t)) + ")"
+}
+
 // keywords maps Monkey's keyword strings to their TokenType values.
 var keywords = map[string]TokenType{
-	"fn":     FUNCTION,
-	"let":    LET,
-	"true":   TRUE,
-	"false":  FALSE,
-	"if":     IF,
-	"else":   ELSE,
-	"return": RETURN,
+	"fn":       FUNCTION,
+	"let":      LET,
+	"true":     TRUE,
+	"false":    FALSE,
+	"if":       IF,
+	"else":     ELSE,
+	"return":   RETURN,
+	"while":    WHILE,
+	"for":      FOR,
+	"in":       IN,
+	"break":    BREAK,
+	"continue": CONTINUE,
+}
+
+// operators maps the spellings of Monkey's operators and delimiters to their TokenType values.
+var operators = map[string]TokenType{
+	"=":  ASSIGN,
+	"+=": PLUS_ASSIGN,
+	"-=": MINUS_ASSIGN,
+	"*=": ASTERISK_ASSIGN,
+	"/=": SLASH_ASSIGN,
+	"%=": PERCENT_ASSIGN,
+	"+":  PLUS,
+	"-":  MINUS,
+	"!":  BANG,
+	"*":  ASTERISK,
+	"/":  SLASH,
+	"%":  PERCENT,
+	"**": POWER,
+	"<":  LT,
+	">":  GT,
+	"<=": LT_EQ,
+	">=": GT_EQ,
+	"&":  AMPERSAND,
+	"|":  PIPE,
+	"^":  CARET,
+	"<<": SHIFT_LEFT,
+	">>": SHIFT_RIGHT,
+	"&&": AND,
+	"||": OR,
+	"==": EQ,
+	"!=": NOT_EQ,
+	",":  COMMA,
+	";":  SEMICOLON,
+	":":  COLON,
+	"(":  LPAREN,
+	")":  RPAREN,
+	"{":  LBRACE,
+	"}":  RBRACE,
+	"[":  LBRACKET,
+	"]":  RBRACKET,
+}
+
+// Operators returns the spellings of Monkey's operators and delimiters mapped to their TokenType values.
+// The returned map is a copy and may be modified freely.
+func Operators() map[string]TokenType {
+	copied := make(map[string]TokenType, len(operators))
+	for spelling, tokenType := range operators {
+		copied[spelling] = tokenType
+	}
+	return copied
 }
 
 // LookupIdent checks the keywords table to see if the given identifier is a reserved keyword.